@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const actionSummaryFileName = "fastlane_action_summary.json"
+
+var (
+	actionSummaryTitleRegexp  = regexp.MustCompile(`\|\s*fastlane summary\s*\|`)
+	actionSummaryBorderRegexp = regexp.MustCompile(`\+(-+\+)+\s*$`)
+	actionSummaryHeaderRegexp = regexp.MustCompile(`\|\s*Step\s*\|\s*Action\s*\|`)
+	actionSummaryRowRegexp    = regexp.MustCompile(`\|\s*([^|]+?)\s*\|\s*([^|]+?)\s*\|\s*(\d+(?:\.\d+)?)\s*\|\s*$`)
+)
+
+// actionTiming is a row of the fastlane summary table, printed at the end of every lane.
+type actionTiming struct {
+	Step            int     `json:"step"`
+	Action          string  `json:"action"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Failed          bool    `json:"failed"`
+}
+
+type laneActionSummary struct {
+	Lane    string         `json:"lane"`
+	Actions []actionTiming `json:"actions"`
+}
+
+// actionSummaryParser collects the actions of the last fastlane summary table in the fastlane output.
+type actionSummaryParser struct {
+	inTable bool
+	actions []actionTiming
+}
+
+func newActionSummaryParser() *actionSummaryParser {
+	return &actionSummaryParser{}
+}
+
+func (p *actionSummaryParser) handleLine(line string) {
+	if actionSummaryTitleRegexp.MatchString(line) {
+		// a retried lane prints a new table
+		p.inTable = true
+		p.actions = nil
+		return
+	}
+	if !p.inTable {
+		return
+	}
+
+	if actionSummaryBorderRegexp.MatchString(line) || actionSummaryHeaderRegexp.MatchString(line) {
+		return
+	}
+
+	match := actionSummaryRowRegexp.FindStringSubmatch(line)
+	if match == nil {
+		p.inTable = false
+		return
+	}
+
+	duration, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return
+	}
+	// The failed action is marked with an emoji instead of the step number
+	step, err := strconv.Atoi(match[1])
+	failed := err != nil
+	if failed {
+		step = len(p.actions) + 1
+	}
+
+	p.actions = append(p.actions, actionTiming{
+		Step:            step,
+		Action:          match[2],
+		DurationSeconds: duration,
+		Failed:          failed,
+	})
+}
+
+func actionSummaries(results []laneResult) []laneActionSummary {
+	var summaries []laneActionSummary
+	for _, result := range results {
+		if result.actionSummary == nil || len(result.actionSummary.actions) == 0 {
+			continue
+		}
+		summaries = append(summaries, laneActionSummary{Lane: result.lane, Actions: result.actionSummary.actions})
+	}
+	return summaries
+}
+
+// reportActionSummary writes the action timings of the lanes to the deploy dir and sends them to analytics.
+func (f FastlaneRunner) reportActionSummary(results []laneResult, deployDir string) {
+	summaries := actionSummaries(results)
+	if len(summaries) == 0 {
+		return
+	}
+
+	for _, summary := range summaries {
+		for _, action := range summary.Actions {
+			f.tracker.logActionTiming(summary.Lane, action)
+		}
+	}
+
+	if deployDir == "" {
+		return
+	}
+
+	b, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		f.logger.Warnf("Failed to encode fastlane action summary: %s", err)
+		return
+	}
+	pth := filepath.Join(deployDir, actionSummaryFileName)
+	if err := os.WriteFile(pth, b, 0644); err != nil {
+		f.logger.Warnf("Failed to write fastlane action summary: %s", err)
+		return
+	}
+
+	f.logger.Println()
+	f.logger.Printf("Action timings of %d lane(s) written to %s", len(summaries), pth)
+	for _, summary := range summaries {
+		var actions []string
+		for _, action := range summary.Actions {
+			actions = append(actions, action.Action+" "+strconv.FormatFloat(action.DurationSeconds, 'f', -1, 64)+"s")
+		}
+		f.logger.Printf("- %s: %s", summary.Lane, strings.Join(actions, ", "))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testActionSummaryOutput = `[12:00:05]: fastlane.tools just saved you 2 minutes! 🎉
+
++------+------------------+-------------+
+|           fastlane summary            |
++------+------------------+-------------+
+| Step | Action           | Time (in s) |
++------+------------------+-------------+
+| 1    | default_platform | 0           |
+| 2    | match            | 12          |
+| 3    | gym              | 245         |
+| 💥   | scan             | 31          |
++------+------------------+-------------+
+
+[12:05:00]: fastlane finished with errors`
+
+func Test_GivenFastlaneSummaryTable_WhenParsed_ThenReceiveActionTimings(t *testing.T) {
+	parser := newActionSummaryParser()
+	for _, line := range strings.Split(testActionSummaryOutput, "\n") {
+		parser.handleLine(line)
+	}
+
+	assert.Equal(t, []actionTiming{
+		{Step: 1, Action: "default_platform", DurationSeconds: 0},
+		{Step: 2, Action: "match", DurationSeconds: 12},
+		{Step: 3, Action: "gym", DurationSeconds: 245},
+		{Step: 4, Action: "scan", DurationSeconds: 31, Failed: true},
+	}, parser.actions)
+	assert.False(t, parser.inTable)
+}
+
+func Test_GivenRetriedLane_WhenParsed_ThenReceiveLastAttemptsTimings(t *testing.T) {
+	parser := newActionSummaryParser()
+	for _, line := range strings.Split(testActionSummaryOutput, "\n") {
+		parser.handleLine(line)
+	}
+	for _, line := range []string{
+		"+------+------------------+-------------+",
+		"|           fastlane summary            |",
+		"+------+------------------+-------------+",
+		"| Step | Action           | Time (in s) |",
+		"+------+------------------+-------------+",
+		"| 1    | scan             | 28          |",
+		"+------+------------------+-------------+",
+		"[12:06:00]: fastlane.tools finished successfully 🎉",
+		"| 2    | not_an_action    | 1           |",
+	} {
+		parser.handleLine(line)
+	}
+
+	assert.Equal(t, []actionTiming{{Step: 1, Action: "scan", DurationSeconds: 28}}, parser.actions)
+}
+
+func Test_GivenLaneResults_WhenCollectingActionSummaries_ThenLanesWithoutTableAreSkipped(t *testing.T) {
+	withTable := newActionSummaryParser()
+	withTable.actions = []actionTiming{{Step: 1, Action: "gym", DurationSeconds: 100}}
+
+	summaries := actionSummaries([]laneResult{
+		{lane: "ios build", actionSummary: withTable},
+		{lane: "ios test", actionSummary: newActionSummaryParser()},
+		{lane: "ios beta", skipped: true},
+	})
+
+	assert.Equal(t, []laneActionSummary{{Lane: "ios build", Actions: withTable.actions}}, summaries)
+}
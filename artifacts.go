@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	artifactExportCopy = "copy"
+	artifactExportMove = "move"
+	artifactExportOff  = "off"
+)
+
+type artifactType struct {
+	name string
+	// suffix is matched against the end of the file name
+	suffix string
+	// fileName is matched against the whole file name, it is used instead of suffix if set
+	fileName   string
+	outputKey  string
+	exportList bool
+}
+
+// artifactTypes are the build artifacts exported the same way as the Xcode and Gradle Steps do.
+var artifactTypes = []artifactType{
+	{name: "ipa", suffix: ".ipa", outputKey: "BITRISE_IPA_PATH", exportList: true},
+	{name: "dSYM", suffix: ".app.dSYM.zip", outputKey: "BITRISE_DSYM_PATH", exportList: true},
+	{name: "apk", suffix: ".apk", outputKey: "BITRISE_APK_PATH", exportList: true},
+	{name: "aab", suffix: ".aab", outputKey: "BITRISE_AAB_PATH", exportList: true},
+	{name: "mapping", fileName: "mapping.txt", outputKey: "BITRISE_MAPPING_PATH"},
+}
+
+// artifactSearchSkipDirs are not searched for artifacts, as they contain dependencies, not build outputs.
+var artifactSearchSkipDirs = map[string]bool{
+	".git":         true,
+	".gradle":      true,
+	"node_modules": true,
+	"Pods":         true,
+	"Carthage":     true,
+	"vendor":       true,
+}
+
+func (t artifactType) matches(pth string) bool {
+	base := filepath.Base(pth)
+	if t.fileName != "" {
+		return base == t.fileName
+	}
+	return strings.HasSuffix(base, t.suffix) && base != t.suffix
+}
+
+// artifactSnapshot holds the modification time of the build artifacts existing before the lanes run.
+type artifactSnapshot struct {
+	searchDirs []string
+	files      map[string]time.Time
+}
+
+// artifactSearchDirs returns the work dir, the deploy dir and the fastlane action output directories set by environment variables.
+// The deploy dir is searched too, as the build outputs are often written directly into it (for example with GYM_OUTPUT_DIRECTORY=$BITRISE_DEPLOY_DIR),
+// the files exported by earlier Steps are not collected, as they are not modified by the lanes.
+// The report files of the Step (run summary, logs, diagnostics) are not collected either, as they do not match any artifact type.
+func artifactSearchDirs(workDir, deployDir string) []string {
+	dirs := []string{workDir}
+	addDir := func(dir string) {
+		for _, searchDir := range dirs {
+			if isPathInDir(dir, searchDir) {
+				return
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+
+	if deployDir != "" {
+		addDir(deployDir)
+	}
+	for _, key := range []string{"GYM_OUTPUT_DIRECTORY", "GRADLE_OUTPUT_DIRECTORY"} {
+		if dir := os.Getenv(key); dir != "" {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(workDir, dir)
+			}
+			addDir(dir)
+		}
+	}
+	return dirs
+}
+
+func isPathInDir(pth, dir string) bool {
+	rel, err := filepath.Rel(dir, pth)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// cleanAbsPath returns the path cleaned and made absolute, so that it can be compared to the walked paths.
+func cleanAbsPath(pth string) string {
+	if abs, err := filepath.Abs(pth); err == nil {
+		pth = abs
+	}
+	return filepath.Clean(pth)
+}
+
+func newArtifactSnapshot(searchDirs []string) (artifactSnapshot, error) {
+	s := artifactSnapshot{
+		files: map[string]time.Time{},
+	}
+	for _, dir := range searchDirs {
+		if dir != "" {
+			s.searchDirs = append(s.searchDirs, cleanAbsPath(dir))
+		}
+	}
+	err := s.walk(func(pth string, info os.FileInfo) {
+		s.files[pth] = info.ModTime()
+	})
+	return s, err
+}
+
+func (s artifactSnapshot) walk(fn func(pth string, info os.FileInfo)) error {
+	for _, dir := range s.searchDirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := filepath.Walk(dir, func(pth string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if artifactSearchSkipDirs[info.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			for _, t := range artifactTypes {
+				if t.matches(pth) {
+					fn(pth, info)
+					break
+				}
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to search for artifacts in %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// newArtifacts returns the artifacts created or modified since the snapshot, grouped by artifact type name.
+func (s artifactSnapshot) newArtifacts() (map[string][]string, error) {
+	artifacts := map[string][]string{}
+	err := s.walk(func(pth string, info os.FileInfo) {
+		if modTime, ok := s.files[pth]; ok && !info.ModTime().After(modTime) {
+			return
+		}
+		for _, t := range artifactTypes {
+			if t.matches(pth) {
+				artifacts[t.name] = append(artifacts[t.name], pth)
+				break
+			}
+		}
+	})
+	for _, pths := range artifacts {
+		sort.Strings(pths)
+	}
+	return artifacts, err
+}
+
+// exportArtifacts exports the artifacts created since the snapshot and returns their paths in the deploy dir,
+// the artifacts built into the deploy dir are exported in place.
+func (f FastlaneRunner) exportArtifacts(snapshot artifactSnapshot, mode, deployDir string) []string {
+	f.logger.Println()
+	f.logger.Infof("Exporting build artifacts")
+
+	if deployDir == "" {
+		f.logger.Warnf("No BITRISE_DEPLOY_DIR found, skipping artifact export")
+		return nil
+	}
+
+	artifacts, err := snapshot.newArtifacts()
+	if err != nil {
+		f.logger.Warnf("%s", err)
+		return nil
+	}
+	if len(artifacts) == 0 {
+		f.logger.Printf("No new build artifacts found")
+		return nil
+	}
+
+	absDeployDir := cleanAbsPath(deployDir)
+
+	var exported []string
+	for _, t := range artifactTypes {
+		var exportedPths []string
+		for _, pth := range artifacts[t.name] {
+			if isPathInDir(pth, absDeployDir) {
+				f.logger.Printf("%s (built into the deploy dir)", pth)
+				exportedPths = append(exportedPths, pth)
+				continue
+			}
+
+			dst := uniqueArtifactPath(deployDir, filepath.Base(pth), t)
+			if err := exportArtifact(pth, dst, mode); err != nil {
+				f.logger.Warnf("Failed to %s %s to %s: %s", mode, pth, deployDir, err)
+				continue
+			}
+			f.logger.Printf("%s -> %s", pth, dst)
+			exportedPths = append(exportedPths, dst)
+		}
+		if len(exportedPths) == 0 {
+			continue
+		}
+		exported = append(exported, exportedPths...)
+
+		outputs := map[string]string{t.outputKey: exportedPths[len(exportedPths)-1]}
+		if t.exportList {
+			outputs[t.outputKey+"_LIST"] = strings.Join(exportedPths, "|")
+		}
+		for _, key := range sortedKeys(outputs) {
+			if err := f.outputExporter.ExportOutput(key, outputs[key]); err != nil {
+				f.logger.Warnf("Failed to export %s: %s", key, err)
+				continue
+			}
+			f.logger.Donef("%s: %s", key, outputs[key])
+		}
+	}
+
+	return exported
+}
+
+// uniqueArtifactPath returns a path in the deploy dir not used by any file yet,
+// for example the mapping.txt files of multiple Android variants are exported as mapping.txt, mapping-1.txt...
+func uniqueArtifactPath(deployDir, name string, t artifactType) string {
+	ext := t.suffix
+	if t.fileName != "" {
+		ext = filepath.Ext(name)
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	pth := filepath.Join(deployDir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(pth); os.IsNotExist(err) {
+			return pth
+		}
+		pth = filepath.Join(deployDir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+}
+
+func exportArtifact(src, dst, mode string) error {
+	if mode == artifactExportMove {
+		if err := os.Rename(src, dst); err == nil {
+			return nil
+		}
+		// Rename fails across file systems, fall back to copy and remove
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		return os.Remove(src)
+	}
+	return copyFile(src, dst)
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := in.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
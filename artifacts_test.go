@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOutputExporter map[string]string
+
+func (e fakeOutputExporter) ExportOutput(key, value string) error {
+	e[key] = value
+	return nil
+}
+
+func Test_GivenArtifactsCreatedByTheRun_WhenExportArtifacts_ThenOnlyNewArtifactsAreExported(t *testing.T) {
+	workDir := t.TempDir()
+	deployDir := t.TempDir()
+	oldIPA := filepath.Join(workDir, "old.ipa")
+	writeTestFile(t, oldIPA)
+	oldTime := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(oldIPA, oldTime, oldTime))
+	writeTestFile(t, filepath.Join(workDir, "node_modules", "dep", "bundled.apk"))
+
+	snapshot, err := newArtifactSnapshot(artifactSearchDirs(workDir, deployDir))
+	assert.NoError(t, err)
+
+	writeTestFile(t, filepath.Join(workDir, "App.ipa"))
+	writeTestFile(t, filepath.Join(workDir, "App.app.dSYM.zip"))
+	writeTestFile(t, filepath.Join(workDir, "app", "build", "outputs", "mapping", "free", "mapping.txt"))
+	writeTestFile(t, filepath.Join(workDir, "app", "build", "outputs", "mapping", "paid", "mapping.txt"))
+	writeTestFile(t, filepath.Join(workDir, "node_modules", "dep", "new.apk"))
+
+	exporter := fakeOutputExporter{}
+	step := FastlaneRunner{logger: log.NewLogger(), outputExporter: exporter}
+	step.exportArtifacts(snapshot, artifactExportCopy, deployDir)
+
+	assert.Equal(t, fakeOutputExporter{
+		"BITRISE_IPA_PATH":       filepath.Join(deployDir, "App.ipa"),
+		"BITRISE_IPA_PATH_LIST":  filepath.Join(deployDir, "App.ipa"),
+		"BITRISE_DSYM_PATH":      filepath.Join(deployDir, "App.app.dSYM.zip"),
+		"BITRISE_DSYM_PATH_LIST": filepath.Join(deployDir, "App.app.dSYM.zip"),
+		"BITRISE_MAPPING_PATH":   filepath.Join(deployDir, "mapping-1.txt"),
+	}, exporter)
+	assert.FileExists(t, filepath.Join(workDir, "App.ipa"))
+	assert.FileExists(t, filepath.Join(deployDir, "mapping.txt"))
+}
+
+func Test_GivenMoveMode_WhenExportArtifacts_ThenArtifactIsMoved(t *testing.T) {
+	workDir := t.TempDir()
+	deployDir := t.TempDir()
+	snapshot, err := newArtifactSnapshot([]string{workDir})
+	assert.NoError(t, err)
+	writeTestFile(t, filepath.Join(workDir, "app-release.aab"))
+
+	exporter := fakeOutputExporter{}
+	step := FastlaneRunner{logger: log.NewLogger(), outputExporter: exporter}
+	step.exportArtifacts(snapshot, artifactExportMove, deployDir)
+
+	assert.Equal(t, filepath.Join(deployDir, "app-release.aab"), exporter["BITRISE_AAB_PATH"])
+	assert.NoFileExists(t, filepath.Join(workDir, "app-release.aab"))
+}
+
+func Test_GivenArtifactBuiltIntoDeployDir_WhenExportArtifacts_ThenItIsExportedInPlace(t *testing.T) {
+	workDir := t.TempDir()
+	deployDir := t.TempDir()
+	previousIPA := filepath.Join(deployDir, "Previous.ipa")
+	writeTestFile(t, previousIPA)
+	oldTime := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(previousIPA, oldTime, oldTime))
+
+	snapshot, err := newArtifactSnapshot(artifactSearchDirs(workDir, deployDir))
+	assert.NoError(t, err)
+	// gym with GYM_OUTPUT_DIRECTORY=$BITRISE_DEPLOY_DIR
+	writeTestFile(t, filepath.Join(deployDir, "App.ipa"))
+	writeTestFile(t, filepath.Join(deployDir, "App.app.dSYM.zip"))
+	writeTestFile(t, filepath.Join(deployDir, "fastlane_run_summary.md"))
+
+	exporter := fakeOutputExporter{}
+	step := FastlaneRunner{logger: log.NewLogger(), outputExporter: exporter}
+	exported := step.exportArtifacts(snapshot, artifactExportCopy, deployDir)
+
+	assert.Equal(t, []string{filepath.Join(deployDir, "App.ipa"), filepath.Join(deployDir, "App.app.dSYM.zip")}, exported)
+	assert.Equal(t, filepath.Join(deployDir, "App.ipa"), exporter["BITRISE_IPA_PATH"])
+	assert.Equal(t, filepath.Join(deployDir, "App.ipa"), exporter["BITRISE_IPA_PATH_LIST"])
+	assert.Equal(t, filepath.Join(deployDir, "App.app.dSYM.zip"), exporter["BITRISE_DSYM_PATH"])
+	assert.NoFileExists(t, filepath.Join(deployDir, "App-1.ipa"))
+}
+
+func Test_GivenDeployDirOrOutputDirInWorkDir_WhenSearchDirsCreated_ThenDirsAreNotSearchedTwice(t *testing.T) {
+	t.Setenv("GYM_OUTPUT_DIRECTORY", "/deploy")
+	t.Setenv("GRADLE_OUTPUT_DIRECTORY", "build/outputs")
+
+	assert.Equal(t, []string{"/src/app", "/deploy"}, artifactSearchDirs("/src/app", "/deploy"))
+	assert.Equal(t, []string{"/src/app", "/deploy"}, artifactSearchDirs("/src/app", "/src/app/deploy"))
+}
+
+func writeTestFile(t *testing.T, pth string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(pth), 0700))
+	assert.NoError(t, os.WriteFile(pth, []byte("content"), 0600))
+}
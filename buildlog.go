@@ -0,0 +1,125 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	buildLogCollectionNever     = "never"
+	buildLogCollectionOnFailure = "on_failure"
+	buildLogCollectionAlways    = "always"
+)
+
+const (
+	buildLogsArchiveName = "fastlane_build_logs.zip"
+	buildLogsOutputKey   = "FASTLANE_BUILD_LOGS_PATH"
+)
+
+func shouldCollectBuildLogs(mode string, failed bool) bool {
+	switch mode {
+	case buildLogCollectionAlways:
+		return true
+	case buildLogCollectionOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
+
+// zipDir archives the files of the dir, keeping their paths relative to the dir, and returns the number of archived files.
+func zipDir(dir, archivePth string) (count int, err error) {
+	archive, err := os.Create(archivePth)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := archive.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	w := zip.NewWriter(archive)
+	defer func() {
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = filepath.Walk(dir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, pth)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+
+		entry, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(pth)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		if _, err := io.Copy(entry, file); err != nil {
+			return err
+		}
+
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// collectBuildLogs archives the logs written by fastlane actions (gym, scan...) to the FL_BUILDLOG_PATH dir into the deploy dir
+// and exports the archive's path.
+func (f FastlaneRunner) collectBuildLogs(buildlogPth, deployDir string) {
+	f.logger.Println()
+	f.logger.Infof("Collecting fastlane build logs")
+
+	if deployDir == "" {
+		f.logger.Warnf("No BITRISE_DEPLOY_DIR found, skipping build log collection")
+		return
+	}
+	if buildlogPth == "" {
+		f.logger.Warnf("No build log directory, skipping build log collection")
+		return
+	}
+
+	archivePth := filepath.Join(deployDir, buildLogsArchiveName)
+	count, err := zipDir(buildlogPth, archivePth)
+	if err != nil {
+		f.logger.Warnf("Failed to archive build logs: %s", err)
+		return
+	}
+	if count == 0 {
+		if err := os.Remove(archivePth); err != nil {
+			f.logger.Warnf("Failed to remove empty build log archive: %s", err)
+		}
+		f.logger.Printf("No build logs found")
+		return
+	}
+
+	if err := f.outputExporter.ExportOutput(buildLogsOutputKey, archivePth); err != nil {
+		f.logger.Warnf("Failed to export %s: %s", buildLogsOutputKey, err)
+		return
+	}
+	f.logger.Donef("%s: %s (%d file(s))", buildLogsOutputKey, archivePth, count)
+}
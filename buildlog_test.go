@@ -0,0 +1,62 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenCollectionMode_WhenCheckingCollection_ThenLogsAreCollectedAccordingly(t *testing.T) {
+	assert.False(t, shouldCollectBuildLogs(buildLogCollectionNever, true))
+	assert.False(t, shouldCollectBuildLogs(buildLogCollectionOnFailure, false))
+	assert.True(t, shouldCollectBuildLogs(buildLogCollectionOnFailure, true))
+	assert.True(t, shouldCollectBuildLogs(buildLogCollectionAlways, false))
+}
+
+func Test_GivenBuildLogs_WhenCollected_ThenArchiveIsExported(t *testing.T) {
+	buildlogDir := t.TempDir()
+	deployDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(buildlogDir, "gym", "App-App.log"), "gym log")
+	writeTestFileContent(t, filepath.Join(buildlogDir, "scan", "App-App.log"), "scan log")
+
+	exporter := fakeOutputExporter{}
+	step := FastlaneRunner{logger: log.NewLogger(), outputExporter: exporter}
+	step.collectBuildLogs(buildlogDir, deployDir)
+
+	archivePth := filepath.Join(deployDir, buildLogsArchiveName)
+	assert.Equal(t, archivePth, exporter[buildLogsOutputKey])
+
+	reader, err := zip.OpenReader(archivePth)
+	assert.NoError(t, err)
+	defer func() {
+		_ = reader.Close()
+	}()
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"gym/App-App.log", "scan/App-App.log"}, names)
+}
+
+func Test_GivenNoBuildLogsOrDeployDir_WhenCollected_ThenNothingIsExported(t *testing.T) {
+	buildlogDir := t.TempDir()
+	deployDir := t.TempDir()
+
+	exporter := fakeOutputExporter{}
+	step := FastlaneRunner{logger: log.NewLogger(), outputExporter: exporter}
+	step.collectBuildLogs(buildlogDir, deployDir)
+
+	_, err := os.Stat(filepath.Join(deployDir, buildLogsArchiveName))
+	assert.True(t, os.IsNotExist(err))
+
+	writeTestFileContent(t, filepath.Join(buildlogDir, "gym", "App-App.log"), "gym log")
+	step.collectBuildLogs(buildlogDir, "")
+
+	assert.Empty(t, exporter)
+}
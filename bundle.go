@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+)
+
+// bundlePathEnvKey is the bundler setting of the directory the gems are installed into.
+const bundlePathEnvKey = "BUNDLE_PATH"
+
+// resolveBundlePath returns the absolute bundle path, a relative path is relative to the work dir.
+func (f FastlaneRunner) resolveBundlePath(bundlePath, workDir string) (string, error) {
+	bundlePath = strings.TrimSpace(bundlePath)
+	if bundlePath == "" {
+		return "", nil
+	}
+	if expanded := os.ExpandEnv(bundlePath); !filepath.IsAbs(expanded) && !strings.HasPrefix(expanded, "~") {
+		return filepath.Join(workDir, expanded), nil
+	}
+	return f.pathModifier.AbsPath(bundlePath)
+}
+
+// bundlerEnvs returns the environment variables configuring bundler to use the Gemfile and the bundle path.
+func bundlerEnvs(gemfilePath, bundlePath string) []string {
+	var envs []string
+	if gemfilePath != "" {
+		envs = append(envs, bundleGemfileEnvKey+"="+gemfilePath)
+	}
+	if bundlePath != "" {
+		envs = append(envs, bundlePathEnvKey+"="+bundlePath)
+	}
+	return envs
+}
+
+// bundleCacheItems returns the bundle path as a cache item, which is only updated if the Gemfile.lock changes.
+func bundleCacheItems(gemfilePath, bundlePath string) []string {
+	if bundlePath == "" {
+		return nil
+	}
+	lockPth := gemfileLockPath(gemfilePath)
+	if info, err := os.Stat(lockPth); lockPth == "" || err != nil || info.IsDir() {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s -> %s", bundlePath, lockPth)}
+}
+
+// bundleCommand creates a `bundle [_version_] <args>` command, using the bundle path.
+func (f FastlaneRunner) bundleCommand(opts EnsureDependenciesOpts, args ...string) command.Command {
+	if version := opts.GemVersions.bundler.Version; version != "" {
+		args = append([]string{fmt.Sprintf("_%s_", version)}, args...)
+	}
+	return f.rbyFactory.Create("bundle", args, &command.Opts{
+		Stdout: f.stdout,
+		Stderr: f.stderr,
+		Dir:    opts.WorkDir,
+		Env:    bundlerEnvs(opts.GemfilePath, opts.BundlePath),
+	})
+}
+
+func (f FastlaneRunner) bundlerVersionCommand(opts EnsureDependenciesOpts) command.Command {
+	return f.bundleCommand(opts, "--version")
+}
+
+func (f FastlaneRunner) bundleCheckCommand(opts EnsureDependenciesOpts) command.Command {
+	return f.bundleCommand(opts, "check")
+}
+
+// installedBundleSatisfied returns whether the required bundler version is installed
+// and the installed gems (for example preinstalled on the stack or restored from the cache) satisfy the Gemfile.lock,
+// in which case neither bundler nor the gems need to be installed.
+func (f FastlaneRunner) installedBundleSatisfied(opts EnsureDependenciesOpts) bool {
+	if !opts.UseBundler {
+		return false
+	}
+
+	f.logger.Println()
+	f.logger.Infof("Checking installed gems")
+
+	if opts.BundlePath != "" {
+		if info, err := os.Stat(opts.BundlePath); err != nil || !info.IsDir() {
+			f.logger.Printf("No gems restored to the bundle path (%s), installing them", opts.BundlePath)
+			return false
+		}
+	}
+
+	bundler := "Bundler"
+	if version := opts.GemVersions.bundler.Version; version != "" {
+		bundler = fmt.Sprintf("Bundler %s", version)
+	}
+
+	cmd := f.bundlerVersionCommand(opts)
+	f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+	if err := cmd.Run(); err != nil {
+		f.logger.Printf("%s is not installed, installing it and the gems", bundler)
+		return false
+	}
+
+	cmd = f.bundleCheckCommand(opts)
+	f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+	if err := cmd.Run(); err != nil {
+		f.logger.Printf("The installed gems do not satisfy the Gemfile.lock, installing them")
+		return false
+	}
+
+	f.logger.Donef("%s is installed and the installed gems satisfy the Gemfile.lock, skipping the bundler and gem install", bundler)
+	return true
+}
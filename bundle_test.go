@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenBundlePathInput_WhenResolved_ThenRelativePathIsRelativeToWorkDir(t *testing.T) {
+	step := FastlaneRunner{pathModifier: pathutil.NewPathModifier()}
+
+	pth, err := step.resolveBundlePath("vendor/bundle", "/src/ios")
+	assert.NoError(t, err)
+	assert.Equal(t, "/src/ios/vendor/bundle", pth)
+
+	pth, err = step.resolveBundlePath("/tmp/bundle", "/src/ios")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/bundle", pth)
+
+	pth, err = step.resolveBundlePath(" ", "/src/ios")
+	assert.NoError(t, err)
+	assert.Equal(t, "", pth)
+}
+
+func Test_GivenBundlePath_WhenEnvsCreated_ThenBundlePathIsSet(t *testing.T) {
+	assert.Equal(t, []string{"BUNDLE_PATH=/src/vendor/bundle"}, bundlerEnvs("", "/src/vendor/bundle"))
+	assert.Equal(t, []string{"BUNDLE_GEMFILE=/src/fastlane/Gemfile", "BUNDLE_PATH=/src/vendor/bundle"}, bundlerEnvs("/src/fastlane/Gemfile", "/src/vendor/bundle"))
+	assert.Nil(t, bundlerEnvs("", ""))
+}
+
+func Test_GivenBundlePath_WhenCacheItemsCollected_ThenBundleIsKeyedOnGemfileLock(t *testing.T) {
+	workDir := t.TempDir()
+	bundlePath := filepath.Join(workDir, "vendor", "bundle")
+
+	gemfilePath := filepath.Join(workDir, "Gemfile")
+	assert.Nil(t, bundleCacheItems(gemfilePath, bundlePath))
+
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile.lock"), "GEM\n  specs:\n    fastlane (2.217.0)\n")
+
+	assert.Equal(t, []string{bundlePath + " -> " + filepath.Join(workDir, "Gemfile.lock")}, bundleCacheItems(gemfilePath, bundlePath))
+	assert.Nil(t, bundleCacheItems(gemfilePath, ""))
+	assert.Nil(t, bundleCacheItems("", bundlePath))
+}
+
+func Test_GivenNoRestoredBundle_WhenChecked_ThenInstallIsNotSkipped(t *testing.T) {
+	workDir := t.TempDir()
+	step := FastlaneRunner{logger: log.NewLogger()}
+	opts := EnsureDependenciesOpts{
+		WorkDir:    workDir,
+		BundlePath: filepath.Join(workDir, "vendor", "bundle"),
+		GemVersions: gemVersions{
+			fastlane: gems.Version{Found: true, Version: "2.217.0"},
+		},
+	}
+
+	assert.False(t, step.installedBundleSatisfied(opts))
+
+	opts.UseBundler = true
+	assert.False(t, step.installedBundleSatisfied(opts))
+}
@@ -20,36 +20,55 @@ type depsFunc func(dir string) ([]string, []string, error)
 
 func (f FastlaneRunner) cacheDeps(opts RunOpts) {
 	if opts.EnableCache {
-		f.logger.Println()
-		f.logger.Infof("Collecting cache")
+		f.commitCache(opts)
+	}
+}
 
-		var depsFuncs = []depsFunc{
-			f.cocoapodsDeps,
-			f.carthageDeps,
-			f.androidDeps,
+// commitCache collects the dependencies of the work dirs and commits them to the cache in one go.
+// The lanes already succeeded at this point, so a failure is only reported as a warning.
+func (f FastlaneRunner) commitCache(runOpts ...RunOpts) {
+	f.logger.Println()
+	f.logger.Infof("Collecting cache")
+
+	c := cache.New()
+	for _, opts := range runOpts {
+		includes, excludes := f.collectCacheItems(opts.WorkDir, opts.GemfilePath, opts.BundlePath)
+		for _, item := range includes {
+			c.IncludePath(item)
 		}
 
-		c := cache.New()
-		for _, depFunc := range depsFuncs {
-			includes, excludes, err := depFunc(opts.WorkDir)
-			f.logger.Debugf("%s found include path:\n%s\nexclude paths:\n%s", f.functionName(depFunc), strings.Join(includes, "\n"), strings.Join(excludes, "\n"))
-			if err != nil {
-				f.logger.Warnf("failed to collect dependencies: %s", err.Error())
-				continue
-			}
-
-			for _, item := range includes {
-				c.IncludePath(item)
-			}
-
-			for _, item := range excludes {
-				c.ExcludePath(item)
-			}
+		for _, item := range excludes {
+			c.ExcludePath(item)
 		}
-		if err := c.Commit(); err != nil {
-			f.logger.Warnf("failed to commit paths to cache: %s", err)
+	}
+	if err := c.Commit(); err != nil {
+		f.logger.Warnf("Failed to commit paths to cache: %s", err)
+		f.tracker.logStepError(string(errorCategoryCache))
+	}
+}
+
+func (f FastlaneRunner) collectCacheItems(workDir, gemfilePath, bundlePath string) ([]string, []string) {
+	var depsFuncs = []depsFunc{
+		f.cocoapodsDeps,
+		f.carthageDeps,
+		f.androidDeps,
+	}
+
+	var includes, excludes []string
+	for _, depFunc := range depsFuncs {
+		i, e, err := depFunc(workDir)
+		f.logger.Debugf("%s found include path:\n%s\nexclude paths:\n%s", f.functionName(depFunc), strings.Join(i, "\n"), strings.Join(e, "\n"))
+		if err != nil {
+			f.logger.Warnf("failed to collect dependencies: %s", err.Error())
+			continue
 		}
+
+		includes = append(includes, i...)
+		excludes = append(excludes, e...)
 	}
+	includes = append(includes, bundleCacheItems(gemfilePath, bundlePath)...)
+
+	return includes, excludes
 }
 
 func (f FastlaneRunner) functionName(i interface{}) string {
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/env"
+)
+
+// cleanEnvAllowedNames are the environment variables passed to fastlane in clean environment mode.
+var cleanEnvAllowedNames = map[string]bool{
+	"PATH": true, "HOME": true, "USER": true, "LOGNAME": true, "SHELL": true, "TMPDIR": true, "TERM": true,
+	"LANG": true, "LANGUAGE": true, "TZ": true, "CI": true, "SSH_AUTH_SOCK": true,
+	"HTTP_PROXY": true, "HTTPS_PROXY": true, "NO_PROXY": true, "ALL_PROXY": true,
+	"http_proxy": true, "https_proxy": true, "no_proxy": true, "all_proxy": true,
+	"SSL_CERT_FILE": true, "SSL_CERT_DIR": true,
+	"DEVELOPER_DIR": true, "JAVA_HOME": true, "ANDROID_HOME": true, "ANDROID_SDK_ROOT": true, "ANDROID_NDK_HOME": true,
+}
+
+// cleanEnvAllowedPrefixes are the prefixes of the environment variables passed to fastlane in clean environment mode:
+// locale, Ruby version manager, RubyGems and bundler settings, the Bitrise build metadata and the envman settings
+// (without ENVMAN_ENVSTORE_PATH the variables exported by the lanes are lost).
+// Variables with these prefixes are dropped if their name suggests a secret (for example BITRISE_BUILD_API_TOKEN).
+var cleanEnvAllowedPrefixes = []string{
+	"LC_", "RUBY", "GEM_", "BUNDLE_", "BUNDLER_", "RBENV_", "ASDF_", "RVM_", "CHRUBY_", "BITRISE_", "ENVMAN_",
+}
+
+// envAllowlist decides which environment variables are passed to fastlane in clean environment mode.
+type envAllowlist struct {
+	names    map[string]bool
+	prefixes []string
+}
+
+// newEnvAllowlist returns the built-in allowlist extended with the given names, a name ending with * is a prefix.
+func newEnvAllowlist(extra []string) *envAllowlist {
+	a := &envAllowlist{names: map[string]bool{}}
+	for _, item := range extra {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+		case strings.HasSuffix(item, "*"):
+			a.prefixes = append(a.prefixes, strings.TrimSuffix(item, "*"))
+		default:
+			a.names[item] = true
+		}
+	}
+	return a
+}
+
+func (a *envAllowlist) allowed(key string) bool {
+	if a.names[key] {
+		return true
+	}
+	for _, prefix := range a.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	if cleanEnvAllowedNames[key] {
+		return true
+	}
+	for _, prefix := range cleanEnvAllowedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return !isSecretKey(key)
+		}
+	}
+	return false
+}
+
+// filter returns the allowed KEY=value pairs and the names of the dropped variables.
+func (a *envAllowlist) filter(environ []string) ([]string, []string) {
+	var kept, dropped []string
+	for _, pair := range environ {
+		key := strings.SplitN(pair, "=", 2)[0]
+		if a.allowed(key) {
+			kept = append(kept, pair)
+		} else {
+			dropped = append(dropped, key)
+		}
+	}
+	sort.Strings(dropped)
+	return kept, dropped
+}
+
+// filteredEnvRepository is an env.Repository listing only the allowed environment variables,
+// the command factories use it as the base environment of the created commands.
+type filteredEnvRepository struct {
+	env.Repository
+	allowlist *envAllowlist
+}
+
+func newFilteredEnvRepository(repository env.Repository, allowlist *envAllowlist) env.Repository {
+	return filteredEnvRepository{Repository: repository, allowlist: allowlist}
+}
+
+// List ...
+func (r filteredEnvRepository) List() []string {
+	kept, _ := r.allowlist.filter(r.Repository.List())
+	return kept
+}
+
+func (f FastlaneRunner) printCleanEnvironment(allowlist *envAllowlist, environ []string) {
+	kept, dropped := allowlist.filter(environ)
+
+	f.logger.Println()
+	f.logger.Infof("Clean environment")
+	f.logger.Printf("Passing %d environment variable(s) to fastlane, dropped %d:", len(kept), len(dropped))
+	for _, key := range dropped {
+		f.logger.Printf("- %s", key)
+	}
+}
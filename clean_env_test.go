@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenEnvironment_WhenFiltered_ThenOnlyAllowedVariablesAreKept(t *testing.T) {
+	allowlist := newEnvAllowlist([]string{"MATCH_PASSWORD", " FIREBASE_*", ""})
+	environ := []string{
+		"PATH=/usr/bin:/bin",
+		"HOME=/Users/vagrant",
+		"LC_ALL=en_US.UTF-8",
+		"GEM_HOME=/Users/vagrant/.gem",
+		"BUNDLE_PATH=vendor/bundle",
+		"BITRISE_BUILD_NUMBER=42",
+		"BITRISE_BUILD_API_TOKEN=build-token",
+		"MATCH_PASSWORD=match-secret",
+		"FIREBASE_APP_ID=1:123:ios:abc",
+		"AWS_SECRET_ACCESS_KEY=aws-secret",
+		"SLACK_URL=https://hooks.slack.com/x",
+		"EMPTY_VALUE=",
+	}
+
+	kept, dropped := allowlist.filter(environ)
+
+	assert.Equal(t, []string{
+		"PATH=/usr/bin:/bin",
+		"HOME=/Users/vagrant",
+		"LC_ALL=en_US.UTF-8",
+		"GEM_HOME=/Users/vagrant/.gem",
+		"BUNDLE_PATH=vendor/bundle",
+		"BITRISE_BUILD_NUMBER=42",
+		"MATCH_PASSWORD=match-secret",
+		"FIREBASE_APP_ID=1:123:ios:abc",
+	}, kept)
+	assert.Equal(t, []string{"AWS_SECRET_ACCESS_KEY", "BITRISE_BUILD_API_TOKEN", "EMPTY_VALUE", "SLACK_URL"}, dropped)
+}
+
+func Test_GivenBuildEnvironment_WhenFiltered_ThenEnvmanProxyAndCertificateVariablesAreKept(t *testing.T) {
+	environ := []string{
+		"ENVMAN_ENVSTORE_PATH=/Users/vagrant/.envman/.envstore.yml",
+		"HTTPS_PROXY=http://proxy.example.com:3128",
+		"no_proxy=localhost,.example.com",
+		"SSL_CERT_FILE=/etc/ssl/custom-ca.pem",
+		"TZ=Europe/Budapest",
+	}
+
+	kept, dropped := newEnvAllowlist(nil).filter(environ)
+
+	assert.Equal(t, environ, kept)
+	assert.Empty(t, dropped)
+}
+
+func Test_GivenUserAllowedSecretLookingName_WhenFiltered_ThenItIsKept(t *testing.T) {
+	allowlist := newEnvAllowlist([]string{"BITRISE_BUILD_API_TOKEN"})
+
+	kept, dropped := allowlist.filter([]string{"BITRISE_BUILD_API_TOKEN=build-token"})
+
+	assert.Equal(t, []string{"BITRISE_BUILD_API_TOKEN=build-token"}, kept)
+	assert.Empty(t, dropped)
+}
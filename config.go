@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/v2/stepconf"
 	"github.com/bitrise-io/go-utils/v2/retryhttp"
@@ -14,8 +15,34 @@ import (
 
 // Inputs ...
 type Inputs struct {
-	InputWorkDir string `env:"work_dir,dir"`
-	Lane         string `env:"lane,required"`
+	InputWorkDir          string `env:"work_dir,dir"`
+	Lane                  string `env:"lane"`
+	ContinueOnLaneFailure bool   `env:"continue_on_lane_failure,opt[yes,no]"`
+	StructuredLaneOptions string `env:"lane_options"`
+	FastlaneEnv           string `env:"env"`
+
+	InputProjects    []string `env:"projects,multiline"`
+	ParallelProjects bool     `env:"parallel_projects,opt[yes,no]"`
+
+	RetryMaxAttempts int      `env:"retry_max_attempts,range[1..10]"`
+	RetryWaitTime    int      `env:"retry_wait_time,range[0..3600]"`
+	RetryPatterns    []string `env:"retry_patterns,multiline"`
+
+	LaneTimeout     int `env:"lane_timeout,range[0..1440]"`
+	NoOutputTimeout int `env:"no_output_timeout,range[0..1440]"`
+
+	FailOnInteractivePrompt bool `env:"fail_on_interactive_prompt,opt[yes,no]"`
+
+	LaneContextKeys    []string `env:"lane_context_keys,multiline"`
+	ArtifactExport     string   `env:"artifact_export,opt[copy,move,off]"`
+	BuildLogCollection string   `env:"build_log_collection,opt[never,on_failure,always]"`
+	RunSummaryPath     string   `env:"run_summary_path"`
+
+	RedactedEnvVars   []string `env:"redacted_env_vars,multiline"`
+	CleanEnvironment  bool     `env:"clean_environment,opt[yes,no]"`
+	AllowedEnvVars    []string `env:"allowed_env_vars,multiline"`
+	IsolatedHome      bool     `env:"isolated_home,opt[yes,no]"`
+	IsolatedHomeLinks []string `env:"isolated_home_links,multiline"`
 
 	BitriseConnection   bitriseConnection `env:"connection,opt[automatic,api_key,apple_id,off]"`
 	AppleID             string            `env:"apple_id"`
@@ -24,11 +51,18 @@ type Inputs struct {
 	APIKeyPath          stepconf.Secret   `env:"api_key_path"`
 	APIIssuer           string            `env:"api_issuer"`
 
-	UpdateFastlane bool `env:"update_fastlane,opt[true,false]"`
-	VerboseLog     bool `env:"verbose_log,opt[yes,no]"`
-	EnableCache    bool `env:"enable_cache,opt[yes,no]"`
+	DryRun bool `env:"dry_run,opt[yes,no]"`
 
-	GemHome string `env:"GEM_HOME"`
+	UpdateFastlane   bool   `env:"update_fastlane,opt[true,false]"`
+	InputGemfilePath string `env:"gemfile_path"`
+	InputBundlePath  string `env:"bundle_path"`
+	VerboseLog       bool   `env:"verbose_log,opt[yes,no]"`
+	EnableCache      bool   `env:"enable_cache,opt[yes,no]"`
+
+	GemHome       string `env:"GEM_HOME"`
+	BundleGemfile string `env:"BUNDLE_GEMFILE"`
+	SourceDir     string `env:"BITRISE_SOURCE_DIR"`
+	DeployDir     string `env:"BITRISE_DEPLOY_DIR"`
 
 	// Used to get Bitrise Apple Developer Portal Connection
 	BuildURL      string          `env:"BITRISE_BUILD_URL"`
@@ -39,9 +73,16 @@ type Inputs struct {
 type Config struct {
 	Inputs
 	WorkDir         string
+	WorkDirReason   string
 	AuthCredentials appleauth.Credentials
-	LaneOptions     []string
+	AuthSource      string
+	Lanes           [][]string
+	DotenvFiles     []dotenvFile
+	RetryPolicy     retryPolicy
 	GemVersions     gemVersions
+	GemfilePath     string
+	BundlePath      string
+	Projects        []project
 }
 
 // ProcessConfig ...
@@ -58,7 +99,7 @@ func (f FastlaneRunner) ProcessConfig() (Config, error) {
 
 	authInputs, err := f.validateAuthInputs(config)
 	if err != nil {
-		return Config{}, fmt.Errorf("Issue with authentication related inputs: %v", err)
+		return Config{}, newStepError(errorCategoryAuth, fmt.Errorf("Issue with authentication related inputs: %v", err))
 	}
 
 	authSources, err := f.parseAuthSources(config.BitriseConnection)
@@ -68,38 +109,195 @@ func (f FastlaneRunner) ProcessConfig() (Config, error) {
 
 	f.validateGemHome(config)
 
-	workDir, err := f.getWorkDir(config)
-	if err != nil {
-		return Config{}, err
+	if len(config.InputProjects) == 0 {
+		workDir, workDirReason, err := f.getWorkDir(config)
+		if err != nil {
+			return Config{}, err
+		}
+		config.WorkDir = workDir
+		config.WorkDirReason = workDirReason
 	}
-	config.WorkDir = workDir
 
 	// Select and fetch Apple authenication source
-	authConfig, err := f.selectAppleAuthSource(config, authSources, authInputs)
+	authConfig, authSource, err := f.selectAppleAuthSource(config, authSources, authInputs)
 	if err != nil {
-		return Config{}, err
+		return Config{}, newStepError(errorCategoryAuth, err)
 	}
 	config.AuthCredentials = authConfig
+	config.AuthSource = authSource
 
-	// Split lane option
-	laneOptions, err := shellquote.Split(config.Lane)
+	retryPolicy, err := newRetryPolicy(config.RetryMaxAttempts, time.Duration(config.RetryWaitTime)*time.Second, config.RetryPatterns)
 	if err != nil {
-		return Config{}, fmt.Errorf("Failed to parse lane (%s), error: %s", config.Lane, err)
+		return Config{}, fmt.Errorf("Invalid Input: %v", err)
+	}
+	config.RetryPolicy = retryPolicy
+
+	if len(config.InputProjects) > 0 {
+		projects, err := f.processProjects(config)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Projects = projects
+		return config, nil
+	}
+
+	return f.processWorkDir(config, config.Lane)
+}
+
+// processWorkDir processes the inputs depending on the work dir: the lanes, the dotenv files and the fastlane version.
+func (f FastlaneRunner) processWorkDir(config Config, laneInput string) (Config, error) {
+	// Split lane options, one lane per line
+	lanes, err := parseLanes(laneInput)
+	if err != nil {
+		return Config{}, err
+	}
+	config.Lanes = lanes
+
+	lanes, err = f.applyStructuredLaneOptions(config.Lanes, config.StructuredLaneOptions)
+	if err != nil {
+		return Config{}, fmt.Errorf("Invalid Input: %v", err)
+	}
+	config.Lanes = lanes
+
+	dotenvFiles, err := f.processDotenvFiles(config.WorkDir, config.FastlaneEnv)
+	if err != nil {
+		return Config{}, fmt.Errorf("Invalid Input: %v", err)
+	}
+	config.DotenvFiles = dotenvFiles
+
+	lanes, err = addDotenvOption(config.Lanes, config.DotenvFiles)
+	if err != nil {
+		return Config{}, fmt.Errorf("Invalid Input: %v", err)
+	}
+	config.Lanes = lanes
+
+	if err := f.validateLanes(config.WorkDir, config.Lanes); err != nil {
+		return Config{}, err
 	}
-	config.LaneOptions = laneOptions
 
 	// Determine desired Fastlane version
 	f.logger.Println()
 	f.logger.Infof("Determine desired Fastlane version")
-	gemVersions, err := f.parseGemfileLock(config.WorkDir)
+	gemfilePath, err := f.resolveGemfile(config)
+	if err != nil {
+		return Config{}, fmt.Errorf("Invalid Input: %v", err)
+	}
+	config.GemfilePath = gemfilePath
+	f.printGemfile(config.GemfilePath)
+
+	gemVersions, err := f.parseGemfileLock(gemfileLockPath(config.GemfilePath))
 	if err != nil {
 		return Config{}, err
 	}
 	config.GemVersions = gemVersions
 
+	if config.InputBundlePath != "" {
+		if !config.GemVersions.fastlane.Found {
+			f.logger.Warnf("fastlane is not installed with bundler (no fastlane gem in the Gemfile.lock), ignoring the bundle path input")
+		} else {
+			bundlePath, err := f.resolveBundlePath(config.InputBundlePath, config.WorkDir)
+			if err != nil {
+				return Config{}, fmt.Errorf("Invalid Input: failed to expand bundle path (%s): %v", config.InputBundlePath, err)
+			}
+			config.BundlePath = bundlePath
+			f.logger.Printf("Installing gems into %s", config.BundlePath)
+		}
+	}
+
+	if err := f.validatePlugins(config.WorkDir, config.GemfilePath); err != nil {
+		return Config{}, fmt.Errorf("Invalid fastlane plugin setup: %w", err)
+	}
+
 	return config, nil
 }
 
+func parseLanes(laneInput string) ([][]string, error) {
+	var lanes [][]string
+	for _, line := range splitLaneLines(laneInput) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		laneOptions, err := shellquote.Split(line)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse lane (%s), error: %s", line, err)
+		}
+		lanes = append(lanes, laneOptions)
+	}
+
+	if len(lanes) == 0 {
+		return nil, fmt.Errorf("No lane specified")
+	}
+
+	return lanes, nil
+}
+
+// splitLaneLines splits the lane input into lines on the newlines outside of quotes,
+// so that a quoted lane option value can span multiple lines.
+func splitLaneLines(laneInput string) []string {
+	var lines []string
+	var line strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range laneInput {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '\n':
+			lines = append(lines, line.String())
+			line.Reset()
+			continue
+		}
+		line.WriteRune(r)
+	}
+	return append(lines, line.String())
+}
+
+func (f FastlaneRunner) validateLanes(workDir string, lanes [][]string) error {
+	f.logger.Println()
+	f.logger.Infof("Validating lanes")
+
+	fastfilePth, isSwift := findFastfile(workDir)
+	if fastfilePth == "" {
+		f.logger.Warnf("No Fastfile found in the working directory (%s), skipping lane validation", workDir)
+		return nil
+	}
+	if isSwift {
+		f.logger.Printf("Fastfile.swift found (%s), skipping lane validation", fastfilePth)
+		return nil
+	}
+
+	fastfile, err := parseFastfile(fastfilePth)
+	if err != nil {
+		f.logger.Warnf("Failed to parse Fastfile, skipping lane validation: %s", err)
+		return nil
+	}
+
+	for _, laneOptions := range lanes {
+		if err := fastfile.validateLane(laneOptions); err != nil {
+			if !fastfile.isComplete() {
+				f.logger.Warnf("%s", err)
+				f.logger.Warnf("The Fastfile might define lanes, which are only known when fastlane runs: %s", strings.Join(fastfile.incompleteReasons, ", "))
+				f.logger.Warnf("Running the lane, fastlane reports if it does not exist")
+				continue
+			}
+			return fmt.Errorf("Invalid lane: %v", err)
+		}
+	}
+
+	f.logger.Donef("Lanes found in %s", fastfilePth)
+	return nil
+}
+
 func (f FastlaneRunner) validateAuthInputs(config Config) (appleauth.Inputs, error) {
 	authInputs := appleauth.Inputs{
 		Username:            config.AppleID,
@@ -153,7 +351,9 @@ func (f FastlaneRunner) validateGemHome(config Config) {
 	f.logger.Warnf("GEM_HOME environment variable is set to:\n%s\nThis can lead to errors as gem lookup path may not contain GEM_HOME.", config.GemHome)
 }
 
-func (f FastlaneRunner) getWorkDir(config Config) (string, error) {
+// getWorkDir returns the expanded work dir, or the detected fastlane directory's parent if the work dir does not contain a Fastfile,
+// and the reason the work dir was chosen.
+func (f FastlaneRunner) getWorkDir(config Config) (string, string, error) {
 	f.logger.Infof("Expand WorkDir")
 
 	workDir := config.InputWorkDir
@@ -161,22 +361,37 @@ func (f FastlaneRunner) getWorkDir(config Config) (string, error) {
 		f.logger.Printf("WorkDir not set, using CurrentWorkingDirectory...")
 		currentDir, err := f.pathModifier.AbsPath(".")
 		if err != nil {
-			return "", fmt.Errorf("Failed to get current dir, error: %s", err)
+			return "", "", fmt.Errorf("Failed to get current dir, error: %s", err)
 		}
 		workDir = currentDir
 	} else {
 		absWorkDir, err := f.pathModifier.AbsPath(workDir)
 		if err != nil {
-			return "", fmt.Errorf("Failed to expand path (%s), error: %s", workDir, err)
+			return "", "", fmt.Errorf("Failed to expand path (%s), error: %s", workDir, err)
 		}
 		workDir = absWorkDir
 	}
 
-	f.logger.Donef("Expanded WorkDir: %s", workDir)
-	return workDir, nil
+	f.logger.Printf("Expanded WorkDir: %s", workDir)
+
+	repositoryDir := ""
+	if config.SourceDir != "" {
+		if absSourceDir, err := f.pathModifier.AbsPath(config.SourceDir); err == nil {
+			repositoryDir = absSourceDir
+		}
+	}
+
+	workDir, reason, err := f.detectWorkDir(workDir, repositoryDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	f.logger.Donef("WorkDir: %s (%s)", workDir, reason)
+	return workDir, reason, nil
 }
 
-func (f FastlaneRunner) selectAppleAuthSource(config Config, authSources []appleauth.Source, authInputs appleauth.Inputs) (appleauth.Credentials, error) {
+// selectAppleAuthSource returns the credentials of the first usable authentication source and the source's description.
+func (f FastlaneRunner) selectAppleAuthSource(config Config, authSources []appleauth.Source, authInputs appleauth.Inputs) (appleauth.Credentials, string, error) {
 	f.logger.Println()
 	f.logger.Infof("Reading Apple Developer Portal authentication data")
 
@@ -195,17 +410,22 @@ func (f FastlaneRunner) selectAppleAuthSource(config Config, authSources []apple
 		}
 	}
 
-	authConfig, err := appleauth.Select(conn, authSources, authInputs)
-	if err != nil {
-		if _, ok := err.(*appleauth.MissingAuthConfigError); !ok {
-			return appleauth.Credentials{}, fmt.Errorf("Could not configure Apple Service authentication: %v", err)
+	// Sources are checked one by one (the same way appleauth.Select does) to know which one was used
+	for _, source := range authSources {
+		authConfig, err := appleauth.Select(conn, []appleauth.Source{source}, authInputs)
+		if err == nil {
+			return authConfig, source.Description(), nil
 		}
-		f.logger.Warnf("No authentication data found matching the selected Apple Service authentication method (%s).", config.BitriseConnection)
-		if conn != nil && (conn.APIKeyConnection == nil && conn.AppleIDConnection == nil) {
-			f.logger.Warnf("%s", notConnected)
+		if _, ok := err.(*appleauth.MissingAuthConfigError); !ok {
+			return appleauth.Credentials{}, "", fmt.Errorf("Could not configure Apple Service authentication: %v", err)
 		}
 	}
-	return authConfig, nil
+
+	f.logger.Warnf("No authentication data found matching the selected Apple Service authentication method (%s).", config.BitriseConnection)
+	if conn != nil && (conn.APIKeyConnection == nil && conn.AppleIDConnection == nil) {
+		f.logger.Warnf("%s", notConnected)
+	}
+	return appleauth.Credentials{}, "", nil
 }
 
 const notConnected = `Connected Apple Developer Portal Account not found.
@@ -87,3 +87,33 @@ func Test_GivenGemHomeEnvironmentVariableIsEmpty_WhenValidateGemHome_ThenLogWarn
 
 	mockedLogger.AssertCalled(t, "Warnf", expectedWarningMessage, expectedGemHomeArray)
 }
+
+func Test_GivenMultilineLaneInput_WhenParseLanes_ThenReceiveLaneOptionsPerLine(t *testing.T) {
+	expectedValue := [][]string{
+		{"ios", "test"},
+		{"ios", "beta", "release_notes:Fixed a crash"},
+	}
+
+	actualValue, err := parseLanes("ios test\n\n  ios beta \"release_notes:Fixed a crash\"\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedValue, actualValue)
+}
+
+func Test_GivenMultilineQuotedLaneOption_WhenParseLanes_ThenOptionIsKept(t *testing.T) {
+	expectedValue := [][]string{
+		{"ios", "beta", "changelog:line1\nline2", "notes:it's\ndone"},
+		{"ios", "test"},
+	}
+
+	actualValue, err := parseLanes("ios beta changelog:\"line1\nline2\" notes:'it'\\''s\ndone'\nios test")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedValue, actualValue)
+}
+
+func Test_GivenEmptyLaneInput_WhenParseLanes_ThenReceiveError(t *testing.T) {
+	_, err := parseLanes(" \n ")
+
+	assert.Error(t, err)
+}
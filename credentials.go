@@ -18,8 +18,36 @@ type fastlaneAPIKey struct {
 	PrivateKey string `json:"key"`
 }
 
-// FastlaneAuthParams converts Apple credentials to Fastlane env vars and arguments
+// FastlaneAuthParams converts Apple credentials to Fastlane env vars and arguments,
+// the App Store Connect API Key is written to a temporary JSON file.
 func FastlaneAuthParams(authConfig appleauth.Credentials) (map[string]string, error) {
+	apiKeyPath := ""
+	if authConfig.APIKey != nil {
+		fastlaneAPIKeyParams, err := json.Marshal(fastlaneAPIKey{
+			IssuerID:   authConfig.APIKey.IssuerID,
+			KeyID:      authConfig.APIKey.KeyID,
+			PrivateKey: authConfig.APIKey.PrivateKey,
+		})
+		if err != nil {
+			return map[string]string{}, fmt.Errorf("failed to marshal Fastane API Key configuration: %v", err)
+		}
+
+		tmpDir, err := pathutil.NormalizedOSTempDirPath("apiKey")
+		if err != nil {
+			return map[string]string{}, err
+		}
+		apiKeyPath = filepath.Join(tmpDir, "api_key.json")
+		if err := os.WriteFile(apiKeyPath, fastlaneAPIKeyParams, os.ModePerm); err != nil {
+			return map[string]string{}, err
+		}
+	}
+
+	return fastlaneAuthEnvs(authConfig, apiKeyPath), nil
+}
+
+// fastlaneAuthEnvs converts Apple credentials to Fastlane env vars, without side effects:
+// the API Key env vars point to apiKeyPath, which is not written.
+func fastlaneAuthEnvs(authConfig appleauth.Credentials, apiKeyPath string) map[string]string {
 	envs := make(map[string]string)
 	if authConfig.AppleID != nil {
 		// Set as environment variables
@@ -44,31 +72,13 @@ func FastlaneAuthParams(authConfig appleauth.Credentials) (map[string]string, er
 	}
 
 	if authConfig.APIKey != nil {
-		fastlaneAPIKeyParams, err := json.Marshal(fastlaneAPIKey{
-			IssuerID:   authConfig.APIKey.IssuerID,
-			KeyID:      authConfig.APIKey.KeyID,
-			PrivateKey: authConfig.APIKey.PrivateKey,
-		})
-		if err != nil {
-			return envs, fmt.Errorf("failed to marshal Fastane API Key configuration: %v", err)
-		}
-
-		tmpDir, err := pathutil.NormalizedOSTempDirPath("apiKey")
-		if err != nil {
-			return envs, err
-		}
-		fastlaneAuthFile := filepath.Join(tmpDir, "api_key.json")
-		if err := os.WriteFile(fastlaneAuthFile, fastlaneAPIKeyParams, os.ModePerm); err != nil {
-			return envs, err
-		}
-
-		envs["APP_STORE_CONNECT_API_KEY_PATH"] = fastlaneAuthFile
+		envs["APP_STORE_CONNECT_API_KEY_PATH"] = apiKeyPath
 		// these seem redundant and might become obsolete soon
-		envs["DELIVER_API_KEY_PATH"] = fastlaneAuthFile
-		envs["PILOT_API_KEY_PATH"] = fastlaneAuthFile
+		envs["DELIVER_API_KEY_PATH"] = apiKeyPath
+		envs["PILOT_API_KEY_PATH"] = apiKeyPath
 		// deliver: "Precheck cannot check In-app purchases with the App Store Connect API Key (yet). Exclude In-app purchases from precheck"
 		envs["PRECHECK_INCLUDE_IN_APP_PURCHASES"] = "false"
 	}
 
-	return envs, nil
+	return envs
 }
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"os"
 	"strings"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/v2/ruby"
 	"github.com/bitrise-io/go-utils/v2/command"
@@ -14,104 +14,162 @@ type EnsureDependenciesOpts struct {
 	UseBundler     bool
 	WorkDir        string
 	UpdateFastlane bool
+	GemfilePath    string
+	BundlePath     string
+}
+
+// Dependencies describes the environment prepared by InstallDependencies.
+type Dependencies struct {
+	RubyVersion string
+	// FastPath is true if the installed gems already satisfied the Gemfile.lock and the install was skipped.
+	FastPath bool
+}
+
+// installStep is a group of commands run by InstallDependencies under a common title.
+type installStep struct {
+	title    string
+	phase    string
+	category errorCategory
+	cmds     []command.Command
+}
+
+// dependencyPhase is the duration of a part of InstallDependencies, reported to the analytics.
+type dependencyPhase struct {
+	name     string
+	duration time.Duration
 }
 
 // InstallDependencies ...
-func (f FastlaneRunner) InstallDependencies(opts EnsureDependenciesOpts) error {
-	f.reportRubyVersion(opts.UseBundler, opts.GemVersions.bundler.Version, opts.WorkDir)
+func (f FastlaneRunner) InstallDependencies(opts EnsureDependenciesOpts) (dependencies Dependencies, err error) {
+	var phases []dependencyPhase
+	timePhase := func(name string, startTime time.Time) {
+		phases = append(phases, dependencyPhase{name: name, duration: time.Since(startTime)})
+	}
+	defer func() {
+		f.tracker.logDependencyPhases(phases, dependencies.FastPath, err == nil)
+	}()
 
-	// Install desired Fastlane version
+	startTime := time.Now()
+	dependencies.RubyVersion = f.reportRubyVersion(opts)
+	timePhase("ruby_version", startTime)
+
+	steps := f.installSteps(opts)
 	if opts.UseBundler {
+		startTime = time.Now()
+		dependencies.FastPath = f.installedBundleSatisfied(opts)
+		timePhase("bundle_check", startTime)
+	}
+	if dependencies.FastPath {
+		steps = nil
+	}
+
+	// Install desired Fastlane version
+	for _, step := range steps {
 		f.logger.Println()
-		f.logger.Infof("Install bundler")
+		f.logger.Infof("%s", step.title)
 
-		// install bundler with `gem install bundler [-v version]`
-		// in some configurations, the command "bundler _1.2.3_" can return 'Command not found', installing bundler solves this
-		cmds := f.rbyFactory.CreateGemInstall("bundler", opts.GemVersions.bundler.Version, false, true, &command.Opts{
-			Stdout: os.Stdout,
-			Stderr: os.Stderr,
-			Dir:    opts.WorkDir,
-		})
-		for _, cmd := range cmds {
+		startTime = time.Now()
+		for _, cmd := range step.cmds {
 			f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
 			f.logger.Println()
 
 			if err := cmd.Run(); err != nil {
-				return err
+				timePhase(step.phase, startTime)
+				return dependencies, newStepError(step.category, err)
 			}
 		}
+		if len(step.cmds) > 0 {
+			timePhase(step.phase, startTime)
+		}
+	}
 
-		// install Gemfile.lock gems with `bundle [_version_] install ...`
-		f.logger.Println()
-		f.logger.Infof("Install Fastlane with bundler")
+	f.logger.Println()
+	f.logger.Infof("Fastlane version")
 
-		cmd := f.rbyFactory.CreateBundleInstall(opts.GemVersions.bundler.Version, &command.Opts{
-			Stdout: os.Stdout,
-			Stderr: os.Stderr,
-			Dir:    opts.WorkDir,
-		})
+	cmd := f.fastlaneVersionCommand(opts)
+	f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
 
-		f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
-		f.logger.Println()
+	startTime = time.Now()
+	err = cmd.Run()
+	timePhase("fastlane_version", startTime)
+	if err != nil {
+		return dependencies, newStepError(errorCategoryFastlaneVersion, err)
+	}
 
-		if err := cmd.Run(); err != nil {
-			return err
-		}
-	} else if opts.UpdateFastlane {
-		f.logger.Println()
-		f.logger.Infof("Update system installed Fastlane")
+	return dependencies, nil
+}
 
-		cmds := f.rbyFactory.CreateGemInstall("fastlane", "", false, false, &command.Opts{
-			Stdout: os.Stdout,
-			Stderr: os.Stderr,
+func (f FastlaneRunner) installSteps(opts EnsureDependenciesOpts) []installStep {
+	cmdOpts := func() *command.Opts {
+		options := &command.Opts{
+			Stdout: f.stdout,
+			Stderr: f.stderr,
 			Dir:    opts.WorkDir,
-		})
-		for _, cmd := range cmds {
-			f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+		}
+		if opts.UseBundler {
+			options.Env = bundlerEnvs(opts.GemfilePath, opts.BundlePath)
+		}
+		return options
+	}
 
-			if err := cmd.Run(); err != nil {
-				return err
-			}
+	if opts.UseBundler {
+		return []installStep{
+			{
+				title:    "Install bundler",
+				phase:    "bundler_install",
+				category: errorCategoryRubyToolchain,
+				// install bundler with `gem install bundler [-v version]`
+				// in some configurations, the command "bundler _1.2.3_" can return 'Command not found', installing bundler solves this
+				cmds: f.rbyFactory.CreateGemInstall("bundler", opts.GemVersions.bundler.Version, false, true, cmdOpts()),
+			},
+			{
+				title:    "Install Fastlane with bundler",
+				phase:    "bundle_install",
+				category: errorCategoryDependencyInstall,
+				// install Gemfile.lock gems with `bundle [_version_] install ...`
+				cmds: []command.Command{f.rbyFactory.CreateBundleInstall(opts.GemVersions.bundler.Version, cmdOpts())},
+			},
+		}
+	} else if opts.UpdateFastlane {
+		return []installStep{
+			{
+				title:    "Update system installed Fastlane",
+				phase:    "fastlane_update",
+				category: errorCategoryDependencyInstall,
+				cmds:     f.rbyFactory.CreateGemInstall("fastlane", "", false, false, cmdOpts()),
+			},
 		}
-	} else {
-		f.logger.Println()
-		f.logger.Infof("Using system installed Fastlane")
 	}
 
-	f.logger.Println()
-	f.logger.Infof("Fastlane version")
+	return []installStep{{title: "Using system installed Fastlane"}}
+}
 
+func (f FastlaneRunner) fastlaneVersionCommand(opts EnsureDependenciesOpts) command.Command {
 	name := "fastlane"
 	args := []string{"--version"}
 	options := &command.Opts{
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
+		Stdout: f.stdout,
+		Stderr: f.stderr,
 		Dir:    opts.WorkDir,
 	}
-	var cmd command.Command
 	if opts.UseBundler {
-		cmd = f.rbyFactory.CreateBundleExec(name, args, opts.GemVersions.bundler.Version, options)
-	} else {
-		cmd = f.rbyFactory.Create(name, args, options)
-	}
-
-	f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
-
-	if err := cmd.Run(); err != nil {
-		return err
+		options.Env = bundlerEnvs(opts.GemfilePath, opts.BundlePath)
+		return f.rbyFactory.CreateBundleExec(name, args, opts.GemVersions.bundler.Version, options)
 	}
-
-	return nil
+	return f.rbyFactory.Create(name, args, options)
 }
 
-func (f FastlaneRunner) reportRubyVersion(useBundler bool, bundlerVersion string, workDir string) {
+// reportRubyVersion prints the selected Ruby version and returns the active one, or an empty string if it can not be determined.
+func (f FastlaneRunner) reportRubyVersion(opts EnsureDependenciesOpts) string {
+	workDir := opts.WorkDir
+
 	if f.rubyEnvironment.RubyInstallType() == ruby.ASDFRuby {
 		f.logger.Println()
 		f.logger.Infof("Checking selected Ruby version")
 
 		cmd := f.rbyFactory.Create("asdf", []string{"current", "ruby"}, &command.Opts{
-			Stderr: os.Stderr,
-			Stdout: os.Stdout,
+			Stderr: f.stderr,
+			Stdout: f.stdout,
 			Dir:    workDir,
 		})
 
@@ -125,8 +183,8 @@ func (f FastlaneRunner) reportRubyVersion(useBundler bool, bundlerVersion string
 		if _, err := f.cmdLocator.LookPath("rbenv"); err == nil {
 
 			cmd := f.rbyFactory.Create("rbenv", []string{"versions"}, &command.Opts{
-				Stderr: os.Stderr,
-				Stdout: os.Stdout,
+				Stderr: f.stderr,
+				Stdout: f.stdout,
 				Dir:    workDir,
 			})
 
@@ -143,8 +201,9 @@ func (f FastlaneRunner) reportRubyVersion(useBundler bool, bundlerVersion string
 	options := &command.Opts{
 		Dir: workDir,
 	}
-	if useBundler {
-		versionCmd = f.rbyFactory.CreateBundleExec("ruby", []string{"--version"}, bundlerVersion, options)
+	if opts.UseBundler {
+		options.Env = bundlerEnvs(opts.GemfilePath, opts.BundlePath)
+		versionCmd = f.rbyFactory.CreateBundleExec("ruby", []string{"--version"}, opts.GemVersions.bundler.Version, options)
 	} else {
 		versionCmd = f.rbyFactory.Create("ruby", []string{"--version"}, options)
 	}
@@ -152,7 +211,7 @@ func (f FastlaneRunner) reportRubyVersion(useBundler bool, bundlerVersion string
 	if err != nil {
 		f.logger.Warnf("Failed to check active Ruby version: %s", err)
 		f.logger.Printf("Output: %s", output)
-		return
+		return ""
 	}
 	// Example output:
 	// ruby 3.2.1 (2023-02-08 revision 31819e82c8) [arm64-darwin22]
@@ -160,10 +219,15 @@ func (f FastlaneRunner) reportRubyVersion(useBundler bool, bundlerVersion string
 	if len(versionSlice) < 2 || versionSlice[0] != "ruby" {
 		f.logger.Warnf("Unrecognized Ruby version: %s", versionSlice)
 	}
+	if len(versionSlice) < 2 {
+		return ""
+	}
 	version := versionSlice[1]
 
 	f.logger.Println()
 	f.logger.Infof("Active Ruby version: %s", version)
 
 	f.tracker.logEffectiveRubyVersion(version)
+
+	return version
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+)
+
+const (
+	diagnosticsArchiveName = "fastlane_diagnostics.zip"
+	diagnosticsOutputKey   = "FASTLANE_DIAGNOSTICS_PATH"
+)
+
+// outputTailMaxLines is the number of the last fastlane output lines included in the diagnostics archive.
+const outputTailMaxLines = 500
+
+// outputTail keeps the last lines of the fastlane output.
+type outputTail struct {
+	mu       sync.Mutex
+	maxLines int
+	lines    []string
+}
+
+func newOutputTail(maxLines int) *outputTail {
+	return &outputTail{maxLines: maxLines}
+}
+
+func (t *outputTail) handleLine(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.maxLines {
+		t.lines = t.lines[len(t.lines)-t.maxLines:]
+	}
+}
+
+func (t *outputTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.lines) == 0 {
+		return ""
+	}
+	return strings.Join(t.lines, "\n") + "\n"
+}
+
+// diagnosticsDependencyFiles returns the existing Gemfile, Gemfile.lock and Pluginfile of the project.
+func diagnosticsDependencyFiles(workDir, gemfilePath string) []string {
+	if gemfilePath == "" {
+		gemfilePath = filepath.Join(workDir, "Gemfile")
+	}
+	pths := []string{
+		gemfilePath,
+		gemfileLockPath(gemfilePath),
+	}
+	if fastfilePth, _ := findFastfile(workDir); fastfilePth != "" {
+		pths = append(pths, filepath.Join(filepath.Dir(fastfilePth), "Pluginfile"))
+	} else {
+		pths = append(pths, filepath.Join(workDir, "fastlane", "Pluginfile"))
+	}
+
+	var existing []string
+	for _, pth := range pths {
+		if info, err := os.Stat(pth); err == nil && !info.IsDir() {
+			existing = append(existing, pth)
+		}
+	}
+	return existing
+}
+
+// diagnosticsSecrets returns every known secret of the run: the secret inputs, the authentication values and the secret lane options.
+func diagnosticsSecrets(summary runSummary) []string {
+	secrets := append([]string{}, summary.secrets...)
+	for _, lane := range summary.lanes {
+		secrets = append(secrets, secretLaneOptionValues(lane.laneOptions)...)
+	}
+	return secrets
+}
+
+// collectDiagnostics archives the information needed to investigate a failed run into the deploy dir, masking the secrets,
+// and exports the archive's path.
+func (f FastlaneRunner) collectDiagnostics(deployDir, workDir, gemfilePath string, summary runSummary, fastlaneEnv string, tail *outputTail) {
+	f.logger.Println()
+	f.logger.Infof("Collecting diagnostics")
+
+	if deployDir == "" {
+		f.logger.Warnf("No BITRISE_DEPLOY_DIR found, skipping diagnostics collection")
+		return
+	}
+
+	tmpDir, err := pathutil.NewPathProvider().CreateTempDir("fastlane_diagnostics")
+	if err != nil {
+		f.logger.Warnf("Failed to create temp dir for diagnostics: %s", err)
+		return
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			f.logger.Warnf("Failed to remove diagnostics temp dir: %s", err)
+		}
+	}()
+
+	secrets := diagnosticsSecrets(summary)
+	files := map[string]string{
+		"run_summary.md": summary.markdown(),
+	}
+	if fastlaneEnv != "" {
+		files["fastlane_env.log"] = fastlaneEnv
+	}
+	if tail != nil {
+		if output := tail.String(); output != "" {
+			files["fastlane_output_tail.log"] = output
+		}
+	}
+	for _, pth := range diagnosticsDependencyFiles(workDir, gemfilePath) {
+		content, err := os.ReadFile(pth)
+		if err != nil {
+			f.logger.Warnf("Failed to read %s: %s", pth, err)
+			continue
+		}
+		// a Gemfile outside of the work dir (for example in the repository root) is added by its name
+		rel, err := filepath.Rel(workDir, pth)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = filepath.Base(pth)
+		}
+		files[rel] = string(content)
+	}
+
+	for name, content := range files {
+		pth := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+			f.logger.Warnf("Failed to create diagnostics dir: %s", err)
+			return
+		}
+		if err := os.WriteFile(pth, []byte(redactSecrets(content, secrets)), 0600); err != nil {
+			f.logger.Warnf("Failed to write diagnostics file (%s): %s", name, err)
+			return
+		}
+	}
+
+	archivePth := filepath.Join(deployDir, diagnosticsArchiveName)
+	if _, err := zipDir(tmpDir, archivePth); err != nil {
+		f.logger.Warnf("Failed to archive diagnostics: %s", err)
+		return
+	}
+
+	if err := f.outputExporter.ExportOutput(diagnosticsOutputKey, archivePth); err != nil {
+		f.logger.Warnf("Failed to export %s: %s", diagnosticsOutputKey, err)
+		return
+	}
+	f.logger.Donef("%s: %s", diagnosticsOutputKey, archivePth)
+	f.logger.Printf("The archive contains no known secret values, it can be attached to support tickets")
+}
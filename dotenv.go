@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	dotenvNameRegexp = regexp.MustCompile(`^[\w.-]+$`)
+	dotenvLineRegexp = regexp.MustCompile(`^(?:export\s+)?([\w.]+)\s*[=:]\s*(.*)$`)
+)
+
+// dotenvFile is a fastlane environment file (.env.<name>) selected with the --env option.
+type dotenvFile struct {
+	name string
+	pth  string
+	vars map[string]string
+}
+
+// parseDotenvNames splits the comma separated environment names, the same way fastlane's --env option does.
+func parseDotenvNames(input string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(input, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !dotenvNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment name (%s)", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// dotenvDirs returns the directories fastlane looks for .env files in: the fastlane directory (where the Fastfile is) and its parent.
+func dotenvDirs(fastfilePth string) []string {
+	fastlaneDir := filepath.Dir(fastfilePth)
+	return []string{fastlaneDir, filepath.Dir(fastlaneDir)}
+}
+
+func findDotenvFile(dirs []string, name string) string {
+	for _, dir := range dirs {
+		pth := filepath.Join(dir, ".env."+name)
+		if info, err := os.Stat(pth); err == nil && !info.IsDir() {
+			return pth
+		}
+	}
+	return ""
+}
+
+// parseDotenv parses the content of a .env file with dotenv semantics:
+// `export` prefixes, comments, single quoted (literal) and double quoted (escaped, multiline) values.
+// Variable references are kept as is, the values are only used for reporting.
+func parseDotenv(content string) (map[string]string, error) {
+	vars := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		match := dotenvLineRegexp.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("invalid line %d: %s", i+1, line)
+		}
+		key, value := match[1], strings.TrimSpace(match[2])
+
+		switch {
+		case strings.HasPrefix(value, `'`) || strings.HasPrefix(value, `"`):
+			quote := value[:1]
+			value = value[1:]
+			start := i
+			for !hasClosingQuote(value, quote) {
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("unterminated quoted value on line %d", start+1)
+				}
+				value += "\n" + lines[i]
+			}
+			value = value[:closingQuoteIndex(value, quote)]
+			if quote == `"` {
+				value = unescapeDotenvValue(value)
+			}
+		default:
+			if idx := strings.Index(value, " #"); idx != -1 {
+				value = strings.TrimSpace(value[:idx])
+			}
+		}
+
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+func closingQuoteIndex(value, quote string) int {
+	for i := 0; i < len(value); i++ {
+		if quote == `"` && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i:i+1] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasClosingQuote(value, quote string) bool {
+	return closingQuoteIndex(value, quote) != -1
+}
+
+func unescapeDotenvValue(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+// processDotenvFiles finds and parses the .env.<name> files of the environments in the env input.
+func (f FastlaneRunner) processDotenvFiles(workDir string, input string) ([]dotenvFile, error) {
+	names, err := parseDotenvNames(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	f.logger.Println()
+	f.logger.Infof("Validating fastlane environments")
+
+	fastfilePth, _ := findFastfile(workDir)
+	if fastfilePth == "" {
+		return nil, fmt.Errorf("no Fastfile found in the working directory (%s), can not locate the .env files", workDir)
+	}
+	dirs := dotenvDirs(fastfilePth)
+
+	var files []dotenvFile
+	for _, name := range names {
+		pth := findDotenvFile(dirs, name)
+		if pth == "" {
+			return nil, fmt.Errorf("environment file .env.%s not found in %s", name, strings.Join(dirs, ", "))
+		}
+
+		content, err := os.ReadFile(pth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read environment file (%s): %w", pth, err)
+		}
+		vars, err := parseDotenv(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse environment file (%s): %w", pth, err)
+		}
+
+		f.logger.Printf("- %s: %d variable(s) in %s", name, len(vars), pth)
+		files = append(files, dotenvFile{name: name, pth: pth, vars: vars})
+	}
+	return files, nil
+}
+
+// addDotenvOption passes the environments to every lane with fastlane's --env option.
+func addDotenvOption(lanes [][]string, files []dotenvFile) ([][]string, error) {
+	if len(files) == 0 {
+		return lanes, nil
+	}
+
+	var names []string
+	for _, file := range files {
+		names = append(names, file.name)
+	}
+
+	var result [][]string
+	for _, laneOptions := range lanes {
+		for _, option := range laneOptions {
+			if option == "--env" || strings.HasPrefix(option, "--env=") {
+				return nil, fmt.Errorf("--env is set in both the lane (%s) and the env input", strings.Join(laneOptions, " "))
+			}
+		}
+		result = append(result, append(append([]string{}, laneOptions...), "--env", strings.Join(names, ",")))
+	}
+	return result, nil
+}
+
+// dotenvAuthOverrides returns the authentication environment variables overridden by the .env files
+// (fastlane loads them with Dotenv.overload), grouped by the file path.
+func dotenvAuthOverrides(files []dotenvFile, authEnvs map[string]string) map[string][]string {
+	overrides := map[string][]string{}
+	for _, file := range files {
+		for key := range file.vars {
+			if _, ok := authEnvs[key]; ok {
+				overrides[file.pth] = append(overrides[file.pth], key)
+			}
+		}
+		sort.Strings(overrides[file.pth])
+	}
+	return overrides
+}
+
+func (f FastlaneRunner) warnDotenvAuthOverrides(files []dotenvFile, authEnvs map[string]string) {
+	overrides := dotenvAuthOverrides(files, authEnvs)
+	for _, file := range files {
+		keys := overrides[file.pth]
+		if len(keys) == 0 {
+			continue
+		}
+		f.logger.Warnf("Environment file (%s) overrides the Fastlane authentication-related environment variable(s) (%s) set by the Step.", file.pth, strings.Join(keys, ", "))
+		f.logger.Infof("To use the Step's Apple Service authentication, remove these variables from the environment file.")
+	}
+}
+
+// dotenvSecretValues returns the values of the .env file variables which name suggests a secret.
+func dotenvSecretValues(files []dotenvFile) []string {
+	var secrets []string
+	for _, file := range files {
+		for key, value := range file.vars {
+			if isSecretKey(key) {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+	return secrets
+}
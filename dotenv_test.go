@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenDotenvContent_WhenParsed_ThenReceiveVariables(t *testing.T) {
+	content := `# Staging environment
+export SCHEME=App Staging
+CONFIGURATION = Release # inline comment
+MATCH_PASSWORD='pa$$ #word'
+RELEASE_NOTES="First line\nSecond \"line\""
+MULTILINE="first
+second"
+EMPTY=
+`
+	vars, err := parseDotenv(content)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"SCHEME":         "App Staging",
+		"CONFIGURATION":  "Release",
+		"MATCH_PASSWORD": "pa$$ #word",
+		"RELEASE_NOTES":  "First line\nSecond \"line\"",
+		"MULTILINE":      "first\nsecond",
+		"EMPTY":          "",
+	}, vars)
+}
+
+func Test_GivenInvalidDotenvContent_WhenParsed_ThenReceiveError(t *testing.T) {
+	_, err := parseDotenv("SCHEME=App\nnot a variable\n")
+	assert.EqualError(t, err, "invalid line 2: not a variable")
+
+	_, err = parseDotenv("NOTES=\"unterminated\n")
+	assert.EqualError(t, err, "unterminated quoted value on line 1")
+}
+
+func Test_GivenEnvInput_WhenProcessed_ThenEnvironmentFilesAreFound(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Fastfile"), "lane :beta do\nend\n")
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", ".env.staging"), "FASTLANE_USER=ci@example.com\n")
+	writeTestFileContent(t, filepath.Join(workDir, ".env.secrets"), "FASTLANE_SESSION=session\nSCHEME=App\n")
+
+	runner := FastlaneRunner{logger: log.NewLogger()}
+	files, err := runner.processDotenvFiles(workDir, "staging, secrets")
+	assert.NoError(t, err)
+	assert.Equal(t, []dotenvFile{
+		{name: "staging", pth: filepath.Join(workDir, "fastlane", ".env.staging"), vars: map[string]string{"FASTLANE_USER": "ci@example.com"}},
+		{name: "secrets", pth: filepath.Join(workDir, ".env.secrets"), vars: map[string]string{"FASTLANE_SESSION": "session", "SCHEME": "App"}},
+	}, files)
+
+	assert.Equal(t, map[string][]string{
+		filepath.Join(workDir, "fastlane", ".env.staging"): {"FASTLANE_USER"},
+		filepath.Join(workDir, ".env.secrets"):             {"FASTLANE_SESSION"},
+	}, dotenvAuthOverrides(files, map[string]string{"FASTLANE_USER": "user", "FASTLANE_SESSION": "session"}))
+	assert.Equal(t, []string{"session"}, dotenvSecretValues(files))
+
+	_, err = runner.processDotenvFiles(workDir, "production")
+	assert.EqualError(t, err, "environment file .env.production not found in "+filepath.Join(workDir, "fastlane")+", "+workDir)
+}
+
+func Test_GivenEnvironments_WhenAddedToLanes_ThenEnvOptionIsPassed(t *testing.T) {
+	files := []dotenvFile{{name: "staging"}, {name: "secrets"}}
+
+	lanes, err := addDotenvOption([][]string{{"ios", "beta"}, {"android", "deploy", "track:internal"}}, files)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"ios", "beta", "--env", "staging,secrets"},
+		{"android", "deploy", "track:internal", "--env", "staging,secrets"},
+	}, lanes)
+
+	_, err = addDotenvOption([][]string{{"beta", "--env", "production"}}, files)
+	assert.Error(t, err)
+}
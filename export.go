@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+)
+
+// outputExporter exports Step outputs, making them available for the subsequent Steps.
+type outputExporter interface {
+	ExportOutput(key, value string) error
+}
+
+type envmanExporter struct {
+	cmdFactory command.Factory
+}
+
+func newEnvmanExporter(cmdFactory command.Factory) outputExporter {
+	return envmanExporter{cmdFactory: cmdFactory}
+}
+
+// ExportOutput ...
+func (e envmanExporter) ExportOutput(key, value string) error {
+	cmd := e.cmdFactory.Create("envman", []string{"add", "--key", key}, &command.Opts{
+		Stdin: strings.NewReader(value),
+	})
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		if out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const failureCategoryOutputKey = "FASTLANE_FAILURE_CATEGORY"
+
+// errorLineRegexp matches the error output of fastlane, Ruby and xcodebuild, as opposed to informational and warning messages:
+// fastlane prints user errors prefixed with [!], RubyGems and Bundler errors start with ERROR, xcodebuild errors contain error:,
+// uncaught Ruby exceptions end with the exception class in parentheses.
+var errorLineRegexp = regexp.MustCompile(`^(\[\d{2}:\d{2}:\d{2}\]: )?(\[!\]|ERROR\b)|\berror: |\((\w+::)*\w+(Error|Exception)\)\s*$`)
+
+// failureCategory is a known cause of fastlane failures, recognized by its error messages.
+type failureCategory struct {
+	name     string
+	title    string
+	patterns []*regexp.Regexp
+	// errorLinePatterns are only matched on error lines, as these messages also appear in the output of successful runs.
+	errorLinePatterns []*regexp.Regexp
+	explanation       string
+	docURL            string
+}
+
+var failureCategories = []failureCategory{
+	{
+		name:  "code_signing",
+		title: "Code signing error",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`No signing certificate ".+" found`),
+			regexp.MustCompile(`No profiles? for '.+' (was|were) found`),
+			regexp.MustCompile(`Provisioning profile ".+" doesn't include signing certificate`),
+			regexp.MustCompile(`errSecInternalComponent`),
+			regexp.MustCompile(`Code Sign(ing)? [Ee]rror`),
+		},
+		errorLinePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`requires a provisioning profile`),
+		},
+		explanation: `The code signing certificate or provisioning profile required by the build is not available.
+Make sure the certificates and profiles are installed before the lane runs (for example with the Manage iOS Code Signing Step or fastlane match),
+and that the export method matches the installed profiles.`,
+		docURL: "https://docs.fastlane.tools/codesigning/troubleshooting/",
+	},
+	{
+		name:  "apple_session_expired",
+		title: "Apple ID session expired or two-factor authentication required",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)your session has expired`),
+			regexp.MustCompile(`(?i)FASTLANE_SESSION.*(expired|invalid)`),
+			regexp.MustCompile(`(?i)Please enter the \d digit code`),
+			regexp.MustCompile(`Spaceship::UnauthorizedAccessError`),
+		},
+		errorLinePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)two-factor authentication`),
+		},
+		explanation: `The Apple ID session is missing or expired, and fastlane asked for two-factor authentication.
+Use an App Store Connect API key connection, or refresh the session of the Apple ID connection and provide an app-specific password.`,
+		docURL: "https://devcenter.bitrise.io/getting-started/configuring-bitrise-steps-that-require-apple-developer-account-data/",
+	},
+	{
+		name:  "invalid_api_key",
+		title: "Invalid App Store Connect API key",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`Authentication credentials are missing or invalid`),
+			regexp.MustCompile(`NOT_AUTHORIZED`),
+			regexp.MustCompile(`OpenSSL::PKey::(EC|PKey)Error`),
+			regexp.MustCompile(`(?i)invalid curve name`),
+		},
+		explanation: `App Store Connect rejected the API key.
+Check that the key ID, the issuer ID and the private key belong together, and that the key has not been revoked.`,
+		docURL: "https://docs.fastlane.tools/app-store-connect-api/",
+	},
+	{
+		name:  "missing_gem",
+		title: "Missing gem",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`Could not find .+ in (any of the sources|locally installed gems|rubygems repository)`),
+			regexp.MustCompile(`Bundler::GemNotFound`),
+		},
+		errorLinePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`cannot load such file -- `),
+		},
+		explanation: `A gem required by the Fastfile or a fastlane plugin is not installed.
+Add the gem to the Gemfile (plugins to the fastlane/Pluginfile), commit the updated Gemfile.lock and run fastlane with bundler.`,
+		docURL: "https://docs.fastlane.tools/getting-started/ios/setup/#use-a-gemfile",
+	},
+	{
+		name:  "xcode_version_mismatch",
+		title: "Xcode version mismatch",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`Selected Xcode version doesn't match your requirement`),
+			regexp.MustCompile(`SDK ".+" cannot be located`),
+			regexp.MustCompile(`(?i)requires Xcode \d+`),
+			regexp.MustCompile(`is not compatible with Xcode`),
+		},
+		explanation: `The project requires a different Xcode version than the one selected on the build machine.
+Select a stack with the required Xcode version, or update the version requirement of the project.`,
+		docURL: "https://docs.fastlane.tools/actions/ensure_xcode_version/",
+	},
+	{
+		name:  "missing_lane",
+		title: "Lane not found",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`Could not find lane '.+'`),
+			regexp.MustCompile(`(?i)lane .+ not found`),
+		},
+		explanation: `The lane does not exist in the Fastfile.
+Check the lane name and platform in the fastlane lane input, and the working directory input.`,
+		docURL: "https://docs.fastlane.tools/advanced/lanes/",
+	},
+	{
+		name:  "ruby_version_incompatible",
+		title: "Incompatible Ruby version",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`Your Ruby version is .+, but your Gemfile specified`),
+			regexp.MustCompile(`requires [Rr]uby version`),
+		},
+		errorLinePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`required_ruby_version`),
+		},
+		explanation: `The active Ruby version is not compatible with the Gemfile or one of the gems.
+Select a compatible Ruby version, for example with a .ruby-version or .tool-versions file, or update the gems.`,
+		docURL: "https://bundler.io/guides/gemfile_ruby.html",
+	},
+	{
+		name:  "google_play_permission",
+		title: "Google Play permission error",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`The caller does not have permission`),
+			regexp.MustCompile(`Google Api Error: .*(forbidden|permission)`),
+			regexp.MustCompile(`(?i)androidpublisher.*403`),
+		},
+		explanation: `The Google Play service account does not have permission to perform the action.
+Check that the service account is invited to the Google Play Console with the required app permissions, and that the Google Play Android Developer API is enabled.`,
+		docURL: "https://docs.fastlane.tools/actions/supply/#setup",
+	},
+}
+
+// failureClassifier records the category of the last known error message in the fastlane output.
+type failureClassifier struct {
+	category *failureCategory
+	line     string
+}
+
+func newFailureClassifier() *failureClassifier {
+	return &failureClassifier{}
+}
+
+func (c *failureClassifier) handleLine(line string) {
+	errorLine := errorLineRegexp.MatchString(line)
+	for i, category := range failureCategories {
+		patterns := category.patterns
+		if errorLine {
+			patterns = append(append([]*regexp.Regexp{}, patterns...), category.errorLinePatterns...)
+		}
+		for _, pattern := range patterns {
+			if pattern.MatchString(line) {
+				c.category = &failureCategories[i]
+				c.line = strings.TrimSpace(line)
+				return
+			}
+		}
+	}
+}
+
+func (f FastlaneRunner) reportFailureCategory(classifier *failureClassifier) {
+	if classifier == nil || classifier.category == nil {
+		return
+	}
+	category := classifier.category
+
+	f.logger.Println()
+	f.logger.Errorf("%s", category.title)
+	f.logger.Printf("Matching output: %s", classifier.line)
+	f.logger.Println()
+	f.logger.Printf("%s", category.explanation)
+	f.logger.Printf("Read more: %s", category.docURL)
+
+	if err := f.outputExporter.ExportOutput(failureCategoryOutputKey, category.name); err != nil {
+		f.logger.Warnf("Failed to export %s: %s", failureCategoryOutputKey, err)
+	}
+	f.tracker.logFailureCategory(category.name)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenKnownErrorMessage_WhenClassified_ThenReceiveCategory(t *testing.T) {
+	tests := []struct {
+		line     string
+		category string
+	}{
+		{line: `error: No signing certificate "iOS Distribution" found: No "iOS Distribution" signing certificate matching team ID`, category: "code_signing"},
+		{line: `[!] Your session has expired. Please login again.`, category: "apple_session_expired"},
+		{line: `[!] Authentication credentials are missing or invalid. - Provide a properly configured and signed bearer token`, category: "invalid_api_key"},
+		{line: `Could not find fastlane-plugin-firebase_app_distribution-0.7.4 in locally installed gems`, category: "missing_gem"},
+		{line: `[!] Selected Xcode version doesn't match your requirement.`, category: "xcode_version_mismatch"},
+		{line: `[!] Could not find lane 'ios betta'. Available lanes: ios beta`, category: "missing_lane"},
+		{line: `Your Ruby version is 2.7.8, but your Gemfile specified ~> 3.2`, category: "ruby_version_incompatible"},
+		{line: `[!] Google Api Error: forbidden: The caller does not have permission`, category: "google_play_permission"},
+		{line: `/src/ios/App.xcodeproj: error: "App" requires a provisioning profile. Select a provisioning profile in the Signing & Capabilities editor.`, category: "code_signing"},
+		{line: `[!] Two-factor authentication is enabled for the Apple ID, but no session was provided`, category: "apple_session_expired"},
+		{line: "kernel_require.rb:85:in `require': cannot load such file -- fastlane/plugin/versioning (LoadError)", category: "missing_gem"},
+		{line: `ERROR:  Error installing fastlane: fastlane-2.219.0 does not satisfy the required_ruby_version >= 2.6`, category: "ruby_version_incompatible"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			classifier := newFailureClassifier()
+
+			classifier.handleLine("[12:00:00]: Driving the lane 'ios beta' 🚀")
+			classifier.handleLine(tt.line)
+
+			if assert.NotNil(t, classifier.category) {
+				assert.Equal(t, tt.category, classifier.category.name)
+			}
+		})
+	}
+}
+
+func Test_GivenUnknownErrorMessage_WhenClassified_ThenReceiveNoCategory(t *testing.T) {
+	classifier := newFailureClassifier()
+
+	classifier.handleLine("[!] Something unexpected happened")
+
+	assert.Nil(t, classifier.category)
+}
+
+func Test_GivenSuccessfulRunOutput_WhenClassified_ThenReceiveNoCategory(t *testing.T) {
+	classifier := newFailureClassifier()
+
+	for _, line := range []string{
+		"[13:37:00]: Error loading plugin 'fastlane-plugin-versioning': cannot load such file -- fastlane/plugin/versioning",
+		"WARNING:  fastlane-plugin-versioning has no required_ruby_version set in its gemspec",
+		"[13:37:01]: Two-factor Authentication (6 digits code) is enabled for account 'dev@example.com'",
+		"[13:37:02]: Successfully loaded the session from FASTLANE_SESSION",
+		`/src/ios/App.xcodeproj: warning: "AppExtension" requires a provisioning profile with the Push Notifications feature.`,
+		"[13:37:30]: ** ARCHIVE SUCCEEDED **",
+	} {
+		classifier.handleLine(line)
+	}
+	assert.Nil(t, classifier.category)
+
+	classifier.handleLine("[!] Something unexpected happened")
+	assert.Nil(t, classifier.category)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// fastfileLocations are the Fastfile paths (relative to the work dir) fastlane looks for.
+var fastfileLocations = []string{
+	filepath.Join("fastlane", "Fastfile"),
+	filepath.Join(".fastlane", "Fastfile"),
+	"Fastfile",
+}
+
+// swiftFastfileLocations are the Fastfile.swift paths (relative to the work dir) fastlane looks for.
+var swiftFastfileLocations = []string{
+	filepath.Join("fastlane", "Fastfile.swift"),
+	filepath.Join(".fastlane", "Fastfile.swift"),
+}
+
+// fastlaneCommands are the fastlane CLI commands, which are not lanes.
+var fastlaneCommands = map[string]bool{
+	"action": true, "actions": true, "add_plugin": true, "docs": true, "enable_auto_complete": true,
+	"env": true, "init": true, "install_plugins": true, "lanes": true, "list": true, "new_action": true,
+	"new_plugin": true, "run": true, "run_server": true, "search_plugins": true, "socket_server": true,
+	"trigger": true, "update_fastlane": true, "update_plugins": true,
+}
+
+// fastlaneFlagsWithValue are the global fastlane CLI options, which take a value.
+var fastlaneFlagsWithValue = map[string]bool{
+	"--env":               true,
+	"--swift_server_port": true,
+}
+
+var (
+	fastfilePlatformRegexp        = regexp.MustCompile(`^platform\s*\(?\s*:"?([\w-]+)"?.*\bdo\b`)
+	fastfileLaneRegexp            = regexp.MustCompile(`^(private_)?lane\s*\(?\s*:"?([\w-]+)"?`)
+	fastfileDefaultPlatformRegexp = regexp.MustCompile(`^default_platform\s*\(?\s*:"?([\w-]+)"?`)
+	fastfileImportRegexp          = regexp.MustCompile(`^import\s*\(?\s*["']([^"']+)["']`)
+	fastfileImportFromGitRegexp   = regexp.MustCompile(`^import_from_git\b`)
+	fastfileAnyImportRegexp       = regexp.MustCompile(`^import\b`)
+	fastfileAnyLaneRegexp         = regexp.MustCompile(`^(private_)?lane\b`)
+	fastfileMetaprogrammingRegexp = regexp.MustCompile(`^(load|require_relative)\b|\b((public_)?send\s*\(?\s*:(private_)?lane\b|(instance_|class_)?eval\b)`)
+	fastfileBlockStartRegexp      = regexp.MustCompile(`(\bdo(\s*\|[^|]*\|)?|^(if|unless|while|until|case|begin|def|class|module)\b.*|(=|\(|\|\||&&)\s*(if|unless|while|until|case|begin)\b.*)$`)
+	fastfileBlockEndRegexp        = regexp.MustCompile(`^end\b`)
+	fastfileInlineBlockEndRegexp  = regexp.MustCompile(`\bend$`)
+)
+
+type fastfileLane struct {
+	platform string
+	name     string
+	private  bool
+}
+
+func (l fastfileLane) String() string {
+	if l.platform == "" {
+		return l.name
+	}
+	return l.platform + " " + l.name
+}
+
+// fastfile holds the lanes statically extracted from a Fastfile and its local imports.
+type fastfile struct {
+	pth             string
+	defaultPlatform string
+	lanes           []fastfileLane
+	// incompleteReasons lists why the Fastfile might define lanes, which are not known without running it:
+	// lanes imported with import_from_git or from computed paths, lanes with computed names and Ruby metaprogramming.
+	incompleteReasons []string
+}
+
+// isComplete returns whether all the lanes of the Fastfile are known from the static parse.
+func (f fastfile) isComplete() bool {
+	return len(f.incompleteReasons) == 0
+}
+
+// findFastfile returns the path of the Fastfile fastlane uses in the work dir and whether it is a Fastfile.swift,
+// or an empty path if no Fastfile is found.
+func findFastfile(workDir string) (string, bool) {
+	for _, location := range append(fastfileLocations, swiftFastfileLocations...) {
+		pth := filepath.Join(workDir, location)
+		if info, err := os.Stat(pth); err == nil && !info.IsDir() {
+			return pth, strings.HasSuffix(pth, ".swift")
+		}
+	}
+	return "", false
+}
+
+func parseFastfile(pth string) (fastfile, error) {
+	f := fastfile{pth: pth}
+	if err := f.parse(pth, map[string]bool{}); err != nil {
+		return fastfile{}, err
+	}
+	return f, nil
+}
+
+func (f *fastfile) parse(pth string, visited map[string]bool) error {
+	if visited[pth] {
+		return nil
+	}
+	visited[pth] = true
+
+	file, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	platform := ""
+	platformDepth := -1
+	depth := 0
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if match := fastfileDefaultPlatformRegexp.FindStringSubmatch(line); match != nil {
+			f.defaultPlatform = match[1]
+		} else if match := fastfilePlatformRegexp.FindStringSubmatch(line); match != nil {
+			platform = match[1]
+			platformDepth = depth
+		} else if match := fastfileLaneRegexp.FindStringSubmatch(line); match != nil && !strings.Contains(line, "#{") {
+			f.lanes = append(f.lanes, fastfileLane{platform: platform, name: match[2], private: match[1] != ""})
+		} else if fastfileAnyLaneRegexp.MatchString(line) {
+			f.incompleteReasons = append(f.incompleteReasons, fmt.Sprintf("lane with a computed name (%s:%d)", pth, lineNumber))
+		} else if match := fastfileImportRegexp.FindStringSubmatch(line); match != nil {
+			importPth := match[1]
+			if !filepath.IsAbs(importPth) {
+				importPth = filepath.Join(filepath.Dir(pth), importPth)
+			}
+			if err := f.parse(importPth, visited); err != nil {
+				return fmt.Errorf("failed to parse imported Fastfile (%s): %w", importPth, err)
+			}
+		} else if fastfileImportFromGitRegexp.MatchString(line) {
+			f.incompleteReasons = append(f.incompleteReasons, fmt.Sprintf("import_from_git (%s:%d)", pth, lineNumber))
+		} else if fastfileAnyImportRegexp.MatchString(line) {
+			f.incompleteReasons = append(f.incompleteReasons, fmt.Sprintf("import with a computed path (%s:%d)", pth, lineNumber))
+		} else if fastfileMetaprogrammingRegexp.MatchString(line) {
+			f.incompleteReasons = append(f.incompleteReasons, fmt.Sprintf("Ruby metaprogramming (%s:%d)", pth, lineNumber))
+		}
+
+		if fastfileBlockEndRegexp.MatchString(line) {
+			depth--
+			if depth == platformDepth {
+				platform = ""
+				platformDepth = -1
+			}
+		} else if fastfileBlockStartRegexp.MatchString(line) && !fastfileInlineBlockEndRegexp.MatchString(line) {
+			depth++
+		}
+	}
+
+	return scanner.Err()
+}
+
+// findLane returns the lane fastlane runs for the given platform and lane name.
+// Lanes defined outside of platform blocks can be run with any platform,
+// if no platform is given, the default platform is used.
+func (f fastfile) findLane(platform, name string) (fastfileLane, bool) {
+	if platform == "" {
+		platform = f.defaultPlatform
+	}
+
+	for _, lane := range f.lanes {
+		if lane.name == name && lane.platform == platform {
+			return lane, true
+		}
+	}
+	for _, lane := range f.lanes {
+		if lane.name == name && lane.platform == "" {
+			return lane, true
+		}
+	}
+	return fastfileLane{}, false
+}
+
+func (f fastfile) isPlatform(name string) bool {
+	if name == "ios" || name == "android" || name == "mac" {
+		return true
+	}
+	for _, lane := range f.lanes {
+		if lane.platform == name {
+			return true
+		}
+	}
+	return false
+}
+
+// laneFromOptions returns the platform and lane name from the fastlane arguments,
+// skipping options (--flag, key:value) and returning false if the arguments are a fastlane command instead of a lane.
+func (f fastfile) laneFromOptions(laneOptions []string) (string, string, bool) {
+	var positional []string
+	for i := 0; i < len(laneOptions); i++ {
+		option := laneOptions[i]
+		if strings.HasPrefix(option, "-") {
+			if fastlaneFlagsWithValue[option] {
+				i++
+			}
+			continue
+		}
+		if strings.Contains(option, ":") {
+			continue
+		}
+		positional = append(positional, option)
+	}
+
+	if len(positional) == 0 || fastlaneCommands[positional[0]] {
+		return "", "", false
+	}
+	if len(positional) > 1 && f.isPlatform(positional[0]) {
+		return positional[0], positional[1], true
+	}
+	return "", positional[0], true
+}
+
+func (f fastfile) publicLanes() []fastfileLane {
+	var lanes []fastfileLane
+	for _, lane := range f.lanes {
+		if !lane.private {
+			lanes = append(lanes, lane)
+		}
+	}
+	sort.Slice(lanes, func(i, j int) bool {
+		return lanes[i].String() < lanes[j].String()
+	})
+	return lanes
+}
+
+// validateLane returns an error if the lane in the fastlane arguments does not exist or is private.
+func (f fastfile) validateLane(laneOptions []string) error {
+	platform, name, ok := f.laneFromOptions(laneOptions)
+	if !ok {
+		return nil
+	}
+	requested := strings.TrimSpace(platform + " " + name)
+
+	lane, found := f.findLane(platform, name)
+	if found && lane.private {
+		return fmt.Errorf("lane '%s' is a private_lane, it can only be called from other lanes", requested)
+	}
+	if found {
+		return nil
+	}
+
+	available := f.publicLanes()
+	var availableNames []string
+	for _, lane := range available {
+		availableNames = append(availableNames, lane.String())
+	}
+
+	message := fmt.Sprintf("lane '%s' not found in %s", requested, f.pth)
+	if suggestion, ok := f.closestLane(platform, name, available); ok {
+		message += fmt.Sprintf(", did you mean '%s'?", suggestion)
+	}
+	return fmt.Errorf("%s\nAvailable lanes: %s", message, strings.Join(availableNames, ", "))
+}
+
+// closestLane returns the lane with the most similar name, if it is close enough to be a typo.
+func (f fastfile) closestLane(platform, name string, lanes []fastfileLane) (fastfileLane, bool) {
+	if platform == "" {
+		platform = f.defaultPlatform
+	}
+
+	var closest fastfileLane
+	closestDistance := -1
+	for _, lane := range lanes {
+		distance := levenshteinDistance(name, lane.name)
+		if lane.platform != "" && lane.platform != platform {
+			distance++
+		}
+		if closestDistance == -1 || distance < closestDistance {
+			closest = lane
+			closestDistance = distance
+		}
+	}
+
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	if closestDistance == -1 || closestDistance > maxDistance {
+		return fastfileLane{}, false
+	}
+	return closest, true
+}
+
+func levenshteinDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFastfile = `# Fastfile
+default_platform(:ios)
+
+import "../Shared/Fastfile"
+import_from_git(url: "https://github.com/org/lanes.git") if ENV["USE_SHARED_LANES"]
+
+lane :lint do
+  swiftlint if is_ci
+end
+
+platform :ios do
+  before_all do |lane, options|
+    setup_ci
+  end
+
+  desc "Run tests"
+  lane :test do
+    scan
+  end
+
+  lane :beta do |options|
+    if options[:skip_build]
+      UI.message("Skipping build")
+    else
+      build
+    end
+    pilot
+  end
+
+  private_lane :build do
+    gym(export_method: "app-store")
+  end
+end
+
+platform :android do
+  lane :deploy do
+    gradle(task: "bundle")
+  end
+end
+`
+
+const testSharedFastfile = `lane :shared_release do
+  puts "shared"
+end
+`
+
+func createTestFastfile(t *testing.T) string {
+	dir := t.TempDir()
+	workDir := filepath.Join(dir, "app")
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Fastfile"), testFastfile)
+	writeTestFileContent(t, filepath.Join(workDir, "Shared", "Fastfile"), testSharedFastfile)
+	return workDir
+}
+
+func writeTestFileContent(t *testing.T, pth, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(pth), 0700))
+	assert.NoError(t, os.WriteFile(pth, []byte(content), 0600))
+}
+
+func Test_GivenFastfile_WhenParsed_ThenReceiveLanesOfPlatformsAndImports(t *testing.T) {
+	workDir := createTestFastfile(t)
+	pth, isSwift := findFastfile(workDir)
+	assert.False(t, isSwift)
+
+	fastfile, err := parseFastfile(pth)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ios", fastfile.defaultPlatform)
+	assert.Equal(t, []string{"import_from_git (" + pth + ":5)"}, fastfile.incompleteReasons)
+	assert.Equal(t, []fastfileLane{
+		{name: "shared_release"},
+		{name: "lint"},
+		{platform: "ios", name: "test"},
+		{platform: "ios", name: "beta"},
+		{platform: "ios", name: "build", private: true},
+		{platform: "android", name: "deploy"},
+	}, fastfile.lanes)
+}
+
+func Test_GivenExpressionBlocks_WhenFastfileParsed_ThenLanesKeepTheirPlatform(t *testing.T) {
+	workDir := t.TempDir()
+	pth := filepath.Join(workDir, "fastlane", "Fastfile")
+	writeTestFileContent(t, pth, `platform :ios do
+  lane :beta do |options|
+    export_method = if options[:adhoc]
+      "ad-hoc"
+    else
+      "app-store"
+    end
+    configuration = case options[:env]
+                    when "staging" then "Staging"
+                    else "Release"
+                    end
+    version = (begin
+      get_version_number
+    rescue
+      "1.0"
+    end)
+    changelog = options[:changelog] || begin
+      changelog_from_git_commits
+    end
+    skip_upload = options[:dry_run] && if is_ci then false else true end
+    gym(export_method: export_method, configuration: configuration) unless skip_upload
+  end
+
+  lane :release do
+    deliver
+  end
+end
+
+lane :lint do
+  swiftlint
+end
+`)
+
+	fastfile, err := parseFastfile(pth)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []fastfileLane{
+		{platform: "ios", name: "beta"},
+		{platform: "ios", name: "release"},
+		{name: "lint"},
+	}, fastfile.lanes)
+}
+
+func Test_GivenLaneOptions_WhenValidateLane_ThenExistingLanesAreAccepted(t *testing.T) {
+	fastfile, err := parseFastfile(filepath.Join(createTestFastfile(t), "fastlane", "Fastfile"))
+	assert.NoError(t, err)
+
+	for _, laneOptions := range [][]string{
+		{"ios", "beta", "skip_build:true"},
+		{"beta"},
+		{"--env", "staging", "android", "deploy"},
+		{"ios", "lint"},
+		{"shared_release"},
+		{"run", "upload_to_testflight"},
+	} {
+		assert.NoError(t, fastfile.validateLane(laneOptions), laneOptions)
+	}
+}
+
+func Test_GivenMistypedLane_WhenValidateLane_ThenReceiveSuggestion(t *testing.T) {
+	fastfile, err := parseFastfile(filepath.Join(createTestFastfile(t), "fastlane", "Fastfile"))
+	assert.NoError(t, err)
+
+	err = fastfile.validateLane([]string{"ios", "betta"})
+
+	assert.EqualError(t, err, "lane 'ios betta' not found in "+fastfile.pth+", did you mean 'ios beta'?\nAvailable lanes: android deploy, ios beta, ios test, lint, shared_release")
+}
+
+func Test_GivenPrivateLane_WhenValidateLane_ThenReceiveError(t *testing.T) {
+	fastfile, err := parseFastfile(filepath.Join(createTestFastfile(t), "fastlane", "Fastfile"))
+	assert.NoError(t, err)
+
+	err = fastfile.validateLane([]string{"ios", "build"})
+
+	assert.EqualError(t, err, "lane 'ios build' is a private_lane, it can only be called from other lanes")
+}
+
+func Test_GivenDynamicLanes_WhenFastfileParsed_ThenParseIsIncomplete(t *testing.T) {
+	workDir := t.TempDir()
+	pth := filepath.Join(workDir, "fastlane", "Fastfile")
+	writeTestFileContent(t, pth, `lane :lint do
+  swiftlint
+end
+
+%w(staging production).each do |env|
+  lane "deploy_#{env}".to_sym do
+    gym
+  end
+end
+
+send(:lane, :release) do
+  deliver
+end
+`)
+
+	fastfile, err := parseFastfile(pth)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []fastfileLane{{name: "lint"}}, fastfile.lanes)
+	assert.Equal(t, []string{
+		"lane with a computed name (" + pth + ":6)",
+		"Ruby metaprogramming (" + pth + ":11)",
+	}, fastfile.incompleteReasons)
+}
+
+func Test_GivenMissingLane_WhenValidateLanes_ThenErrorOnlyIfParseIsComplete(t *testing.T) {
+	step := FastlaneRunner{logger: log.NewLogger()}
+
+	completeDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(completeDir, "fastlane", "Fastfile"), "lane :lint do\n  swiftlint\nend\n")
+	assert.Error(t, step.validateLanes(completeDir, [][]string{{"deploy_staging"}}))
+
+	incompleteDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(incompleteDir, "fastlane", "Fastfile"), "import_from_git(url: \"https://github.com/org/lanes.git\")\n\nlane :lint do\n  swiftlint\nend\n")
+	assert.NoError(t, step.validateLanes(incompleteDir, [][]string{{"deploy_staging"}}))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bundleGemfileEnvKey is the bundler setting of the Gemfile to use.
+const bundleGemfileEnvKey = "BUNDLE_GEMFILE"
+
+// gemfileNames are the Gemfile names bundler looks for, in its order of precedence.
+var gemfileNames = []string{"gems.rb", "Gemfile"}
+
+// gemfileLockPath returns the lockfile of the Gemfile, the same way as bundler: gems.rb is locked in gems.locked,
+// other Gemfiles in <Gemfile>.lock.
+func gemfileLockPath(gemfilePath string) string {
+	if gemfilePath == "" {
+		return ""
+	}
+	if filepath.Base(gemfilePath) == "gems.rb" {
+		return filepath.Join(filepath.Dir(gemfilePath), "gems.locked")
+	}
+	return gemfilePath + ".lock"
+}
+
+// gemfileCandidates returns the Gemfile locations searched if the Gemfile is not set:
+// the work dir, the fastlane directory of the work dir and the directories above the work dir up to the repository root.
+func gemfileCandidates(workDir, repositoryDir string) []string {
+	dirs := []string{workDir, filepath.Join(workDir, "fastlane")}
+	if rel, err := filepath.Rel(repositoryDir, workDir); repositoryDir != "" && err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		for dir := filepath.Dir(workDir); ; dir = filepath.Dir(dir) {
+			dirs = append(dirs, dir)
+			if dir == repositoryDir || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+
+	var candidates []string
+	for _, dir := range dirs {
+		for _, name := range gemfileNames {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+	return candidates
+}
+
+// findGemfile returns the first existing candidate Gemfile.
+// A lockfile without its Gemfile is an error, as bundler can not install the locked gems without the Gemfile.
+func findGemfile(candidates []string) (string, error) {
+	for _, candidate := range candidates {
+		if isFile(candidate) {
+			return candidate, nil
+		}
+		if lockPath := gemfileLockPath(candidate); isFile(lockPath) {
+			return "", fmt.Errorf("the gem lockfile (%s) exists, but its Gemfile (%s) does not", lockPath, candidate)
+		}
+	}
+	return "", nil
+}
+
+func isFile(pth string) bool {
+	info, err := os.Stat(pth)
+	return err == nil && !info.IsDir()
+}
+
+// resolveGemfile returns the Gemfile used to install and run fastlane with bundler, or an empty string if there is none.
+// The Gemfile input takes precedence over the BUNDLE_GEMFILE Environment Variable, if none of them is set, the Gemfile is searched for.
+// A relative path is relative to the work dir.
+func (f FastlaneRunner) resolveGemfile(config Config) (string, error) {
+	gemfile, source := strings.TrimSpace(config.InputGemfilePath), "gemfile_path input"
+	if gemfile == "" {
+		gemfile, source = strings.TrimSpace(config.BundleGemfile), bundleGemfileEnvKey+" Environment Variable"
+	}
+
+	if gemfile != "" {
+		if expanded := os.ExpandEnv(gemfile); !filepath.IsAbs(expanded) && !strings.HasPrefix(expanded, "~") {
+			gemfile = filepath.Join(config.WorkDir, expanded)
+		} else {
+			absGemfile, err := f.pathModifier.AbsPath(gemfile)
+			if err != nil {
+				return "", fmt.Errorf("failed to expand the Gemfile path (%s) of the %s: %v", gemfile, source, err)
+			}
+			gemfile = absGemfile
+		}
+		if !isFile(gemfile) {
+			return "", fmt.Errorf("the Gemfile (%s) of the %s does not exist", gemfile, source)
+		}
+		return gemfile, nil
+	}
+
+	repositoryDir := ""
+	if config.SourceDir != "" {
+		if absSourceDir, err := f.pathModifier.AbsPath(config.SourceDir); err == nil {
+			repositoryDir = absSourceDir
+		}
+	}
+	return findGemfile(gemfileCandidates(config.WorkDir, repositoryDir))
+}
+
+func (f FastlaneRunner) printGemfile(gemfilePath string) {
+	if gemfilePath == "" {
+		f.logger.Printf("No Gemfile found")
+		return
+	}
+	f.logger.Printf("Gemfile: %s", gemfilePath)
+
+	lockPath := gemfileLockPath(gemfilePath)
+	if _, err := os.Stat(lockPath); err != nil {
+		f.logger.Printf("Gem lockfile: %s (does not exist)", lockPath)
+		return
+	}
+	f.logger.Printf("Gem lockfile: %s", lockPath)
+}
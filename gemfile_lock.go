@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/bitrise-io/go-steputils/command/gems"
 	"github.com/bitrise-io/go-utils/log"
 )
@@ -9,15 +11,20 @@ type gemVersions struct {
 	fastlane, bundler gems.Version
 }
 
-func (f FastlaneRunner) parseGemfileLock(searchDir string) (gemVersions, error) {
-	content, err := gems.GemFileLockContent(searchDir)
+func (f FastlaneRunner) parseGemfileLock(lockPath string) (gemVersions, error) {
+	if lockPath == "" {
+		f.logger.Printf("Gem lockfile does not exist")
+		return gemVersions{}, nil
+	}
+	b, err := os.ReadFile(lockPath)
 	if err != nil {
-		if err == gems.ErrGemLockNotFound {
+		if os.IsNotExist(err) {
 			f.logger.Printf("Gem lockfile does not exist")
 			return gemVersions{}, nil
 		}
 		return gemVersions{}, err
 	}
+	content := string(b)
 
 	var gemVersions gemVersions
 
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenGemfile_WhenLockPathDetermined_ThenBundlerNamingIsUsed(t *testing.T) {
+	assert.Equal(t, "/src/Gemfile.lock", gemfileLockPath("/src/Gemfile"))
+	assert.Equal(t, "/src/gems.locked", gemfileLockPath("/src/gems.rb"))
+	assert.Equal(t, "/src/fastlane/Gemfile.fastlane.lock", gemfileLockPath("/src/fastlane/Gemfile.fastlane"))
+	assert.Equal(t, "", gemfileLockPath(""))
+}
+
+func Test_GivenGemfileOutsideWorkDir_WhenResolved_ThenGemfileIsFound(t *testing.T) {
+	root := t.TempDir()
+	workDir := filepath.Join(root, "apps", "ios")
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Fastfile"), "lane :beta do\nend\n")
+
+	step := FastlaneRunner{pathModifier: pathutil.NewPathModifier()}
+	config := Config{WorkDir: workDir}
+	config.SourceDir = root
+
+	gemfile, err := step.resolveGemfile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, "", gemfile)
+
+	writeTestFileContent(t, filepath.Join(root, "Gemfile"), "gem 'fastlane'\n")
+	gemfile, err = step.resolveGemfile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "Gemfile"), gemfile)
+
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Gemfile"), "gem 'fastlane'\n")
+	gemfile, err = step.resolveGemfile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(workDir, "fastlane", "Gemfile"), gemfile)
+
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile"), "gem 'fastlane'\n")
+	gemfile, err = step.resolveGemfile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(workDir, "Gemfile"), gemfile)
+}
+
+func Test_GivenGemfileInputOrBundleGemfile_WhenResolved_ThenItTakesPrecedence(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile"), "gem 'fastlane'\n")
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile.ci"), "gem 'fastlane'\n")
+
+	step := FastlaneRunner{pathModifier: pathutil.NewPathModifier()}
+	config := Config{WorkDir: workDir}
+	config.BundleGemfile = filepath.Join(workDir, "Gemfile.ci")
+
+	gemfile, err := step.resolveGemfile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(workDir, "Gemfile.ci"), gemfile)
+
+	config.InputGemfilePath = "Gemfile"
+	gemfile, err = step.resolveGemfile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(workDir, "Gemfile"), gemfile)
+
+	config.InputGemfilePath = "fastlane/Gemfile"
+	_, err = step.resolveGemfile(config)
+	assert.EqualError(t, err, "the Gemfile ("+filepath.Join(workDir, "fastlane", "Gemfile")+") of the gemfile_path input does not exist")
+}
+
+func Test_GivenMissingBundleGemfile_WhenResolved_ThenErrorNamesTheVariable(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile"), "gem 'fastlane'\n")
+
+	step := FastlaneRunner{pathModifier: pathutil.NewPathModifier()}
+	config := Config{WorkDir: workDir}
+	config.BundleGemfile = filepath.Join(workDir, "Gemfile.ci")
+
+	_, err := step.resolveGemfile(config)
+	assert.EqualError(t, err, "the Gemfile ("+filepath.Join(workDir, "Gemfile.ci")+") of the BUNDLE_GEMFILE Environment Variable does not exist")
+}
+
+func Test_GivenLockfileWithoutGemfile_WhenResolved_ThenReceiveError(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Gemfile.lock"), "GEM\n")
+
+	step := FastlaneRunner{pathModifier: pathutil.NewPathModifier()}
+
+	_, err := step.resolveGemfile(Config{WorkDir: workDir})
+	assert.EqualError(t, err, "the gem lockfile ("+filepath.Join(workDir, "fastlane", "Gemfile.lock")+") exists, but its Gemfile ("+filepath.Join(workDir, "fastlane", "Gemfile")+") does not")
+}
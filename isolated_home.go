@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isolatedHomeMaxReportedFiles limits the number of left behind files printed.
+const isolatedHomeMaxReportedFiles = 20
+
+// isolatedHomeVersionManagers are the Ruby version manager root variables and their default dirs (relative to HOME),
+// they are set to the real dirs, as the version managers find the installed Rubies relative to HOME by default.
+var isolatedHomeVersionManagers = []struct {
+	key string
+	dir string
+}{
+	{key: "RBENV_ROOT", dir: ".rbenv"},
+	{key: "ASDF_DATA_DIR", dir: ".asdf"},
+	{key: "RVM_PATH", dir: ".rvm"},
+}
+
+// isolatedHomeGitLinks are always linked into the isolated HOME: git (used by match and import_from_git) reads its settings and stored credentials from HOME.
+var isolatedHomeGitLinks = []string{".gitconfig", ".git-credentials"}
+
+// isolatedHomeMacOSLinks are always linked into the isolated HOME on macOS:
+// the code signing tools (match, cert, sigh, gym) find the keychains and the installed provisioning profiles in HOME.
+var isolatedHomeMacOSLinks = []string{"Library/Keychains", "Library/MobileDevice/Provisioning Profiles"}
+
+// isolatedHomeLinks returns the built-in links of the OS followed by the given links, without duplicates.
+func isolatedHomeLinks(goos string, links []string) []string {
+	all := append([]string{}, isolatedHomeGitLinks...)
+	if goos == "darwin" {
+		all = append(all, isolatedHomeMacOSLinks...)
+	}
+	all = append(all, links...)
+
+	seen := map[string]bool{}
+	var unique []string
+	for _, link := range all {
+		link = strings.TrimSpace(link)
+		if link == "" || seen[filepath.Clean(link)] {
+			continue
+		}
+		seen[filepath.Clean(link)] = true
+		unique = append(unique, link)
+	}
+	return unique
+}
+
+// isolatedHome is a fresh HOME dir for a Step run, containing symlinks to the kept dirs of the real HOME.
+type isolatedHome struct {
+	pth      string
+	realHome string
+	links    []string
+}
+
+func newIsolatedHome(realHome string, links []string) (*isolatedHome, error) {
+	pth, err := os.MkdirTemp("", "fastlane_home")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create isolated HOME: %w", err)
+	}
+	h := &isolatedHome{pth: pth, realHome: realHome}
+
+	for _, link := range links {
+		link = filepath.Clean(strings.TrimSpace(link))
+		if link == "." || link == "" {
+			continue
+		}
+		if filepath.IsAbs(link) || link == ".." || strings.HasPrefix(link, ".."+string(filepath.Separator)) {
+			return h, fmt.Errorf("linked path (%s) should be relative to HOME", link)
+		}
+
+		src := filepath.Join(realHome, link)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		dst := filepath.Join(pth, link)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return h, fmt.Errorf("failed to link %s into the isolated HOME: %w", link, err)
+		}
+		if err := os.Symlink(src, dst); err != nil {
+			return h, fmt.Errorf("failed to link %s into the isolated HOME: %w", link, err)
+		}
+		h.links = append(h.links, link)
+	}
+
+	return h, nil
+}
+
+// envs returns the environment variables pointing fastlane to the isolated HOME.
+func (h *isolatedHome) envs() []string {
+	envs := []string{"HOME=" + h.pth}
+	for _, manager := range isolatedHomeVersionManagers {
+		if os.Getenv(manager.key) != "" {
+			continue
+		}
+		dir := filepath.Join(h.realHome, manager.dir)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			envs = append(envs, manager.key+"="+dir)
+		}
+	}
+	return envs
+}
+
+// leftovers returns the files created in the isolated HOME (outside of the linked dirs), relative to the isolated HOME.
+func (h *isolatedHome) leftovers() ([]string, error) {
+	var files []string
+	err := filepath.Walk(h.pth, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// symlinks are not followed, the linked dirs are kept
+		if info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(h.pth, pth)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// remove removes the isolated HOME, the symlinks are removed without touching the linked dirs.
+func (h *isolatedHome) remove() error {
+	return os.RemoveAll(h.pth)
+}
+
+func (f FastlaneRunner) printIsolatedHome(h *isolatedHome) {
+	f.logger.Println()
+	f.logger.Infof("Isolated HOME")
+	f.logger.Printf("Running fastlane with HOME=%s", h.pth)
+	for _, link := range h.links {
+		f.logger.Printf("- %s -> %s", link, filepath.Join(h.realHome, link))
+	}
+}
+
+// cleanupIsolatedHome reports the files left behind in the isolated HOME and removes it.
+func (f FastlaneRunner) cleanupIsolatedHome(h *isolatedHome) {
+	f.logger.Println()
+	f.logger.Infof("Removing isolated HOME")
+
+	files, err := h.leftovers()
+	if err != nil {
+		f.logger.Warnf("Failed to list the files left behind in the isolated HOME: %s", err)
+	}
+	if len(files) > 0 {
+		f.logger.Printf("%d file(s) left behind in the isolated HOME:", len(files))
+		for i, file := range files {
+			if i == isolatedHomeMaxReportedFiles {
+				f.logger.Printf("- ... and %d more", len(files)-i)
+				break
+			}
+			f.logger.Printf("- %s", file)
+		}
+	}
+
+	if err := h.remove(); err != nil {
+		f.logger.Warnf("Failed to remove isolated HOME (%s): %s", h.pth, err)
+		return
+	}
+	f.logger.Donef("Removed %s", h.pth)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenLinkedDirs_WhenIsolatedHomeCreated_ThenExistingDirsAreLinked(t *testing.T) {
+	realHome := t.TempDir()
+	writeTestFileContent(t, filepath.Join(realHome, ".gradle", "caches", "modules", "file.jar"), "jar")
+	writeTestFileContent(t, filepath.Join(realHome, ".fastlane", "spaceship", "cookie"), "session")
+	assert.NoError(t, os.MkdirAll(filepath.Join(realHome, ".rbenv"), 0700))
+
+	home, err := newIsolatedHome(realHome, []string{".gradle/caches", ".gem", ""})
+	assert.NoError(t, err)
+	defer func() {
+		_ = home.remove()
+	}()
+
+	assert.Equal(t, []string{".gradle/caches"}, home.links)
+	content, err := os.ReadFile(filepath.Join(home.pth, ".gradle", "caches", "modules", "file.jar"))
+	assert.NoError(t, err)
+	assert.Equal(t, "jar", string(content))
+	_, err = os.Stat(filepath.Join(home.pth, ".fastlane"))
+	assert.True(t, os.IsNotExist(err))
+
+	if os.Getenv("RBENV_ROOT") == "" {
+		assert.Contains(t, home.envs(), "RBENV_ROOT="+filepath.Join(realHome, ".rbenv"))
+	}
+	assert.Equal(t, "HOME="+home.pth, home.envs()[0])
+}
+
+func Test_GivenOS_WhenIsolatedHomeLinksCreated_ThenSigningAndGitPathsAreLinked(t *testing.T) {
+	assert.Equal(t, []string{
+		".gitconfig",
+		".git-credentials",
+		"Library/Keychains",
+		"Library/MobileDevice/Provisioning Profiles",
+		".gradle/caches",
+	}, isolatedHomeLinks("darwin", []string{".gradle/caches", "", "Library/Keychains/"}))
+
+	assert.Equal(t, []string{".gitconfig", ".git-credentials", ".gradle/caches"}, isolatedHomeLinks("linux", []string{".gradle/caches"}))
+}
+
+func Test_GivenProvisioningProfilesDir_WhenIsolatedHomeCreated_ThenPathWithSpaceIsLinked(t *testing.T) {
+	realHome := t.TempDir()
+	writeTestFileContent(t, filepath.Join(realHome, "Library", "MobileDevice", "Provisioning Profiles", "app.mobileprovision"), "profile")
+	writeTestFileContent(t, filepath.Join(realHome, ".gitconfig"), "[user]\n")
+
+	home, err := newIsolatedHome(realHome, isolatedHomeLinks("darwin", nil))
+	assert.NoError(t, err)
+	defer func() {
+		_ = home.remove()
+	}()
+
+	assert.Equal(t, []string{".gitconfig", "Library/MobileDevice/Provisioning Profiles"}, home.links)
+	assert.FileExists(t, filepath.Join(home.pth, "Library", "MobileDevice", "Provisioning Profiles", "app.mobileprovision"))
+}
+
+func Test_GivenAbsoluteLink_WhenIsolatedHomeCreated_ThenReceiveError(t *testing.T) {
+	home, err := newIsolatedHome(t.TempDir(), []string{"/etc"})
+	assert.EqualError(t, err, "linked path (/etc) should be relative to HOME")
+	assert.NoError(t, home.remove())
+}
+
+func Test_GivenFilesLeftBehind_WhenIsolatedHomeCleanedUp_ThenLinkedDirsAreKept(t *testing.T) {
+	realHome := t.TempDir()
+	cachedFile := filepath.Join(realHome, ".gradle", "caches", "file.jar")
+	writeTestFileContent(t, cachedFile, "jar")
+
+	home, err := newIsolatedHome(realHome, []string{".gradle/caches"})
+	assert.NoError(t, err)
+	writeTestFileContent(t, filepath.Join(home.pth, ".fastlane", "spaceship", "user", "cookie"), "session")
+	writeTestFileContent(t, filepath.Join(home.pth, ".gradle", "caches", "new.jar"), "jar")
+
+	leftovers, err := home.leftovers()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(".fastlane", "spaceship", "user", "cookie")}, leftovers)
+
+	step := FastlaneRunner{logger: log.NewLogger()}
+	step.cleanupIsolatedHome(home)
+
+	_, err = os.Stat(home.pth)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(cachedFile)
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(realHome, ".gradle", "caches", "new.jar"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const laneContextPathEnvKey = "BITRISE_FASTLANE_LANE_CONTEXT_PATH"
+
+// laneContextHook is required into every Ruby process started by fastlane (through RUBYOPT),
+// it dumps fastlane's lane_context (the SharedValues set by the actions) to a JSON file when the process exits.
+const laneContextHook = `# Injected by the Bitrise fastlane Step to export the lane_context
+at_exit do
+  begin
+    output_path = ENV['` + laneContextPathEnvKey + `']
+    if output_path && defined?(Fastlane::Actions) && Fastlane::Actions.respond_to?(:lane_context)
+      require 'json'
+      context = Fastlane::Actions.lane_context.map { |key, value| [key.to_s, value] }.to_h
+      File.write(output_path, JSON.generate(context))
+    end
+  rescue StandardError => e
+    warn "Failed to export fastlane lane_context: #{e}"
+  end
+end
+`
+
+// laneContextOutputs are the lane_context keys always exported as Step outputs (prefixed with FASTLANE_).
+var laneContextOutputs = []string{
+	"IPA_OUTPUT_PATH",
+	"DSYM_OUTPUT_PATH",
+	"XCODEBUILD_ARCHIVE",
+	"GRADLE_APK_OUTPUT_PATH",
+	"GRADLE_AAB_OUTPUT_PATH",
+	"GRADLE_MAPPING_TXT_OUTPUT_PATH",
+	"BUILD_NUMBER",
+	"VERSION_NUMBER",
+}
+
+var invalidEnvKeyCharRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// laneContextCapture holds the files used to capture the lane_context of fastlane runs.
+type laneContextCapture struct {
+	hookPth   string
+	outputPth string
+}
+
+// newLaneContextCapture writes the hook into the dir.
+// RUBYOPT is split on whitespace, so the hook can not be required from a path containing whitespace.
+func newLaneContextCapture(dir string) (laneContextCapture, error) {
+	hookPth := filepath.Join(dir, "lane_context_hook.rb")
+	if strings.IndexFunc(hookPth, unicode.IsSpace) != -1 {
+		return laneContextCapture{}, fmt.Errorf("the lane_context hook path (%s) contains whitespace, which is not supported in RUBYOPT, skipping the lane_context export", hookPth)
+	}
+	if err := os.WriteFile(hookPth, []byte(laneContextHook), 0600); err != nil {
+		return laneContextCapture{}, fmt.Errorf("failed to write lane_context hook: %w", err)
+	}
+
+	return laneContextCapture{
+		hookPth:   hookPth,
+		outputPth: filepath.Join(dir, "lane_context.json"),
+	}, nil
+}
+
+// envs returns the environment variables injecting the hook into fastlane.
+func (c laneContextCapture) envs() []string {
+	rubyOpt := strings.TrimSpace(os.Getenv("RUBYOPT") + " -r" + c.hookPth)
+	return []string{
+		"RUBYOPT=" + rubyOpt,
+		laneContextPathEnvKey + "=" + c.outputPth,
+	}
+}
+
+// read returns the lane_context dumped by the last fastlane run and removes the dump,
+// so that the next lane starts with an empty capture.
+func (c laneContextCapture) read() (map[string]interface{}, error) {
+	content, err := os.ReadFile(c.outputPth)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = os.Remove(c.outputPth)
+	}()
+
+	decoder := json.NewDecoder(strings.NewReader(string(content)))
+	decoder.UseNumber()
+	var laneContext map[string]interface{}
+	if err := decoder.Decode(&laneContext); err != nil {
+		return nil, fmt.Errorf("failed to parse lane_context: %w", err)
+	}
+	return laneContext, nil
+}
+
+func laneContextValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number, bool:
+		return fmt.Sprintf("%v", v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+}
+
+func laneContextOutputKey(key string) string {
+	return "FASTLANE_" + invalidEnvKeyCharRegexp.ReplaceAllString(strings.ToUpper(key), "_")
+}
+
+func (f FastlaneRunner) exportLaneContext(laneContext map[string]interface{}, additionalKeys []string) {
+	f.logger.Println()
+	f.logger.Infof("Exporting lane_context values")
+
+	if len(laneContext) == 0 {
+		f.logger.Printf("No lane_context values found")
+		return
+	}
+
+	exported := map[string]bool{}
+	for _, key := range append(laneContextOutputs, additionalKeys...) {
+		key = strings.TrimSpace(key)
+		if key == "" || exported[key] {
+			continue
+		}
+		exported[key] = true
+
+		value, ok := laneContext[key]
+		if !ok {
+			f.logger.Debugf("%s is not set in the lane_context", key)
+			continue
+		}
+
+		outputKey := laneContextOutputKey(key)
+		outputValue := laneContextValueString(value)
+		if err := f.outputExporter.ExportOutput(outputKey, outputValue); err != nil {
+			f.logger.Warnf("Failed to export %s: %s", outputKey, err)
+			continue
+		}
+		f.logger.Donef("%s: %s", outputKey, outputValue)
+	}
+}
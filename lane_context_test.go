@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenLaneContextDump_WhenRead_ThenReceiveValuesAndDumpIsRemoved(t *testing.T) {
+	capture, err := newLaneContextCapture(t.TempDir())
+	assert.NoError(t, err)
+	dump := `{"IPA_OUTPUT_PATH":"/tmp/app.ipa","BUILD_NUMBER":42,"SIGH_PROFILE_PATHS":["/tmp/a.mobileprovision"]}`
+	assert.NoError(t, os.WriteFile(capture.outputPth, []byte(dump), 0600))
+
+	laneContext, err := capture.read()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/app.ipa", laneContextValueString(laneContext["IPA_OUTPUT_PATH"]))
+	assert.Equal(t, "42", laneContextValueString(laneContext["BUILD_NUMBER"]))
+	assert.Equal(t, `["/tmp/a.mobileprovision"]`, laneContextValueString(laneContext["SIGH_PROFILE_PATHS"]))
+	assert.NoFileExists(t, capture.outputPth)
+}
+
+func Test_GivenTempDirWithWhitespace_WhenCaptureCreated_ThenReceiveError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "temp dir")
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+
+	_, err := newLaneContextCapture(dir)
+
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "lane_context_hook.rb"))
+}
+
+func Test_GivenNoLaneContextDump_WhenRead_ThenReceiveNoValues(t *testing.T) {
+	capture, err := newLaneContextCapture(t.TempDir())
+	assert.NoError(t, err)
+
+	laneContext, err := capture.read()
+
+	assert.NoError(t, err)
+	assert.Empty(t, laneContext)
+}
+
+func Test_GivenLaneContextKey_WhenOutputKey_ThenReceivePrefixedEnvKey(t *testing.T) {
+	assert.Equal(t, "FASTLANE_IPA_OUTPUT_PATH", laneContextOutputKey("IPA_OUTPUT_PATH"))
+	assert.Equal(t, "FASTLANE_MY_PLUGIN_VALUE", laneContextOutputKey("my-plugin.value"))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseStructuredLaneOptions parses the lane options input, which is either a YAML (or JSON) map or a path to a file containing one.
+func (f FastlaneRunner) parseStructuredLaneOptions(input string) (map[string]interface{}, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+
+	content := input
+	if !strings.Contains(input, "\n") {
+		if pth, err := f.pathModifier.AbsPath(input); err == nil {
+			if info, err := os.Stat(pth); err == nil && !info.IsDir() {
+				b, err := os.ReadFile(pth)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read lane options file (%s): %w", pth, err)
+				}
+				f.logger.Printf("Reading lane options from %s", pth)
+				content = string(b)
+			}
+		}
+	}
+
+	var options map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &options); err != nil {
+		return nil, fmt.Errorf("lane options should be a YAML or JSON map (or a path to a file containing one): %w", err)
+	}
+	return options, nil
+}
+
+// laneOptionArgs converts the options to fastlane `key:value` arguments.
+// The arguments are passed to fastlane without a shell, so values can contain any character, fastlane splits them at the first colon.
+// Lists and maps are passed in JSON format.
+func laneOptionArgs(options map[string]interface{}) ([]string, error) {
+	var keys []string
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		if strings.ContainsAny(key, ": ") {
+			return nil, fmt.Errorf("invalid lane option key (%s): keys can not contain colons or spaces", key)
+		}
+
+		var value string
+		switch v := options[key].(type) {
+		case nil:
+			value = ""
+		case string:
+			value = v
+		case bool, int, int64, uint64, float64:
+			value = fmt.Sprintf("%v", v)
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert lane option (%s) to JSON: %w", key, err)
+			}
+			value = string(b)
+		}
+		args = append(args, key+":"+value)
+	}
+	return args, nil
+}
+
+func laneOptionKey(option string) (string, bool) {
+	if strings.HasPrefix(option, "-") {
+		return "", false
+	}
+	idx := strings.Index(option, ":")
+	if idx <= 0 {
+		return "", false
+	}
+	return option[:idx], true
+}
+
+// mergeLaneOptions appends the structured options to the lane options.
+// Structured options take precedence: a key given in both places is removed from the lane options.
+func mergeLaneOptions(laneOptions []string, structuredArgs []string) ([]string, []string) {
+	structuredKeys := map[string]bool{}
+	for _, arg := range structuredArgs {
+		if key, ok := laneOptionKey(arg); ok {
+			structuredKeys[key] = true
+		}
+	}
+
+	var merged []string
+	var overridden []string
+	for _, option := range laneOptions {
+		if key, ok := laneOptionKey(option); ok && structuredKeys[key] {
+			overridden = append(overridden, key)
+			continue
+		}
+		merged = append(merged, option)
+	}
+	return append(merged, structuredArgs...), overridden
+}
+
+// applyStructuredLaneOptions merges the options of the lane options input into every lane.
+func (f FastlaneRunner) applyStructuredLaneOptions(lanes [][]string, input string) ([][]string, error) {
+	options, err := f.parseStructuredLaneOptions(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(options) == 0 {
+		return lanes, nil
+	}
+
+	args, err := laneOptionArgs(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged [][]string
+	for _, laneOptions := range lanes {
+		laneOptions, overridden := mergeLaneOptions(laneOptions, args)
+		if len(overridden) > 0 {
+			f.logger.Warnf("Lane option(s) %s are set in both the lane and the lane options input, using the lane options input", overridden)
+		}
+		merged = append(merged, laneOptions)
+	}
+	return merged, nil
+}
+
+// secretLaneOptionValues returns the values of the options which name suggests a secret.
+func secretLaneOptionValues(laneOptions []string) []string {
+	var secrets []string
+	for _, option := range laneOptions {
+		if key, ok := laneOptionKey(option); ok && isSecretKey(key) {
+			secrets = append(secrets, option[len(key)+1:])
+		}
+	}
+	return secrets
+}
+
+// printLaneOptions prints the key:value options of the lane, masking the secrets.
+func (f FastlaneRunner) printLaneOptions(laneOptions []string, secrets []string) {
+	var lines []string
+	for _, option := range laneOptions {
+		key, ok := laneOptionKey(option)
+		if !ok {
+			continue
+		}
+		value := option[len(key)+1:]
+		if isSecretKey(key) && value != "" {
+			value = redactedValue
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", key, redactSecrets(value, secrets)))
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	f.logger.Printf("Lane options:")
+	for _, line := range lines {
+		f.logger.Printf("%s", line)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenYAMLLaneOptions_WhenApplied_ThenStructuredOptionsOverrideLaneOptions(t *testing.T) {
+	step := FastlaneRunner{logger: log.NewLogger(), pathModifier: pathutil.NewPathModifier()}
+	input := `release_notes: |
+  Fixed: crash on "launch"
+build_number: 42
+skip_waiting: true
+metadata:
+  env: staging
+`
+
+	lanes, err := step.applyStructuredLaneOptions([][]string{{"ios", "beta", "build_number:1", "--verbose"}}, input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{
+		"ios", "beta", "--verbose",
+		"build_number:42",
+		`metadata:{"env":"staging"}`,
+		"release_notes:Fixed: crash on \"launch\"\n",
+		"skip_waiting:true",
+	}}, lanes)
+}
+
+func Test_GivenLaneOptionsFile_WhenApplied_ThenOptionsAreReadFromFile(t *testing.T) {
+	step := FastlaneRunner{logger: log.NewLogger(), pathModifier: pathutil.NewPathModifier()}
+	pth := filepath.Join(t.TempDir(), "options.json")
+	writeTestFileContent(t, pth, `{"groups": ["qa", "beta testers"]}`)
+
+	lanes, err := step.applyStructuredLaneOptions([][]string{{"beta"}}, pth)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"beta", `groups:["qa","beta testers"]`}}, lanes)
+}
+
+func Test_GivenInvalidLaneOptions_WhenApplied_ThenReceiveError(t *testing.T) {
+	step := FastlaneRunner{logger: log.NewLogger(), pathModifier: pathutil.NewPathModifier()}
+
+	_, err := step.applyStructuredLaneOptions([][]string{{"beta"}}, "- not\n- a map")
+
+	assert.Error(t, err)
+}
+
+func Test_GivenSecrets_WhenRedacted_ThenSecretsAreMasked(t *testing.T) {
+	secrets := append(secretLaneOptionValues([]string{"beta", "api_token:tok123", "groups:qa"}), "pass", "password1", "")
+
+	redacted := redactSecrets(`fastlane beta "api_token:tok123" "groups:qa" password1 pass`, secrets)
+
+	assert.Equal(t, `fastlane beta "api_token:[REDACTED]" "groups:qa" [REDACTED] [REDACTED]`, redacted)
+}
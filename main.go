@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/v2/ruby"
 	"github.com/bitrise-io/go-steputils/v2/stepconf"
@@ -22,35 +25,64 @@ func main() {
 func run() ExitCode {
 	logger := log.NewLogger()
 	buildStep := createStep(logger)
+	// flush the analytics events on every path, the failure events are only sent when the Step fails
+	defer buildStep.tracker.wait()
 
 	config, err := buildStep.ProcessConfig()
 	if err != nil {
 		buildStep.logger.Println()
 		buildStep.logger.Errorf(errorutil.FormattedError(fmt.Errorf("Failed to process Step inputs: %w", err)))
-		return Failure
+		return buildStep.reportError(err, errorCategoryConfig)
 	}
 
-	dependenciesOpts := EnsureDependenciesOpts{
-		GemVersions:    config.GemVersions,
-		UseBundler:     config.GemVersions.fastlane.Found,
-		WorkDir:        config.WorkDir,
-		UpdateFastlane: config.UpdateFastlane,
+	if len(config.Projects) > 0 {
+		return runProjects(buildStep, config)
 	}
 
-	if err := buildStep.InstallDependencies(dependenciesOpts); err != nil {
+	dependenciesOpts := createDependenciesOptions(config)
+	runOpts := createRunOptions(config)
+
+	if config.DryRun {
+		if err := buildStep.Plan(dependenciesOpts, runOpts); err != nil {
+			buildStep.logger.Println()
+			buildStep.logger.Errorf(errorutil.FormattedError(fmt.Errorf("Failed to create execution plan: %w", err)))
+			return buildStep.reportError(err, errorCategoryConfig)
+		}
+		return Success
+	}
+
+	dependencies, err := buildStep.InstallDependencies(dependenciesOpts)
+	if err != nil {
 		buildStep.logger.Println()
 		buildStep.logger.Errorf(errorutil.FormattedError(fmt.Errorf("Failed to install Step dependencies: %w", err)))
-		return Failure
+		return buildStep.reportError(err, errorCategoryDependencyInstall)
 	}
+	runOpts.RubyVersion = dependencies.RubyVersion
 
-	runOpts := createRunOptions(config)
 	if err := buildStep.Run(runOpts); err != nil {
 		buildStep.logger.Println()
 		logger.Errorf(errorutil.FormattedError(fmt.Errorf("Failed to execute Step: %w", err)))
-		return Failure
+		return buildStep.reportError(err, errorCategoryLane)
+	}
+
+	return Success
+}
+
+func runProjects(buildStep FastlaneRunner, config Config) ExitCode {
+	if config.DryRun {
+		if err := buildStep.PlanProjects(config); err != nil {
+			buildStep.logger.Println()
+			buildStep.logger.Errorf(errorutil.FormattedError(fmt.Errorf("Failed to create execution plan: %w", err)))
+			return buildStep.reportError(err, errorCategoryConfig)
+		}
+		return Success
 	}
 
-	buildStep.tracker.wait()
+	if err := buildStep.RunProjects(config); err != nil {
+		buildStep.logger.Println()
+		buildStep.logger.Errorf(errorutil.FormattedError(fmt.Errorf("Failed to execute Step: %w", err)))
+		return buildStep.reportError(err, errorCategoryLane)
+	}
 
 	return Success
 }
@@ -69,8 +101,9 @@ func createStep(logger log.Logger) FastlaneRunner {
 
 	pathModifier := pathutil.NewPathModifier()
 	tracker := newStepTracker(envRepository, logger)
+	outputExporter := newEnvmanExporter(cmdFactory)
 
-	return NewFastlaneRunner(inputParser, logger, cmdLocator, cmdFactory, rbyFactory, rubyEnv, pathModifier, tracker)
+	return NewFastlaneRunner(inputParser, logger, cmdLocator, cmdFactory, rbyFactory, rubyEnv, pathModifier, tracker, outputExporter)
 }
 
 // FastlaneRunner ...
@@ -83,6 +116,9 @@ type FastlaneRunner struct {
 	rubyEnvironment ruby.Environment
 	pathModifier    pathutil.PathModifier
 	tracker         stepTracker
+	outputExporter  outputExporter
+	stdout          io.Writer
+	stderr          io.Writer
 }
 
 // NewFastlaneRunner ...
@@ -95,6 +131,7 @@ func NewFastlaneRunner(
 	rubyEnvironment ruby.Environment,
 	pathModifier pathutil.PathModifier,
 	tracker stepTracker,
+	outputExporter outputExporter,
 ) FastlaneRunner {
 	return FastlaneRunner{
 		inputParser:     stepInputParser,
@@ -105,16 +142,56 @@ func NewFastlaneRunner(
 		rubyEnvironment: rubyEnvironment,
 		pathModifier:    pathModifier,
 		tracker:         tracker,
+		outputExporter:  outputExporter,
+		stdout:          os.Stdout,
+		stderr:          os.Stderr,
+	}
+}
+
+func createDependenciesOptions(config Config) EnsureDependenciesOpts {
+	return EnsureDependenciesOpts{
+		GemVersions:    config.GemVersions,
+		UseBundler:     config.GemVersions.fastlane.Found,
+		WorkDir:        config.WorkDir,
+		UpdateFastlane: config.UpdateFastlane,
+		GemfilePath:    config.GemfilePath,
+		BundlePath:     config.BundlePath,
 	}
 }
 
 func createRunOptions(config Config) RunOpts {
+	var allowlist *envAllowlist
+	if config.CleanEnvironment {
+		allowlist = newEnvAllowlist(config.AllowedEnvVars)
+	}
+
 	return RunOpts{
-		WorkDir:         config.WorkDir,
-		AuthCredentials: config.AuthCredentials,
-		LaneOptions:     config.LaneOptions,
-		UseBundler:      config.GemVersions.fastlane.Found,
-		GemVersions:     config.GemVersions,
-		EnableCache:     config.EnableCache,
+		WorkDir:               config.WorkDir,
+		WorkDirReason:         config.WorkDirReason,
+		AuthCredentials:       config.AuthCredentials,
+		AuthSource:            config.AuthSource,
+		Lanes:                 config.Lanes,
+		DotenvFiles:           config.DotenvFiles,
+		ContinueOnLaneFailure: config.ContinueOnLaneFailure,
+		RetryPolicy:           config.RetryPolicy,
+		EnvAllowlist:          allowlist,
+		IsolatedHome:          config.IsolatedHome,
+		IsolatedHomeLinks:     isolatedHomeLinks(runtime.GOOS, config.IsolatedHomeLinks),
+		LaneContextKeys:       config.LaneContextKeys,
+		ArtifactExport:        config.ArtifactExport,
+		BuildLogCollection:    config.BuildLogCollection,
+		RunSummaryPath:        config.RunSummaryPath,
+		DeployDir:             config.DeployDir,
+		Secrets:               append(secretInputValues(config.Inputs), envSecretValues(config.RedactedEnvVars)...),
+		Timeouts: timeoutOpts{
+			timeout:         time.Duration(config.LaneTimeout) * time.Minute,
+			noOutputTimeout: time.Duration(config.NoOutputTimeout) * time.Minute,
+		},
+		FailOnInteractivePrompt: config.FailOnInteractivePrompt,
+		UseBundler:              config.GemVersions.fastlane.Found,
+		GemVersions:             config.GemVersions,
+		GemfilePath:             config.GemfilePath,
+		BundlePath:              config.BundlePath,
+		EnableCache:             config.EnableCache,
 	}
 }
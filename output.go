@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+)
+
+var ansiEscapeRegexp = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// lineHandler is called with every line of the fastlane output, without the trailing newline and ANSI color codes.
+type lineHandler func(line string)
+
+// lineWriter splits the output written into it to lines and passes them to the line handlers.
+// It is safe to use the same lineWriter for both the stdout and stderr of a command.
+type lineWriter struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	handlers []lineHandler
+}
+
+func newLineWriter(handlers ...lineHandler) *lineWriter {
+	return &lineWriter{handlers: handlers}
+}
+
+// Write ...
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx == -1 {
+			break
+		}
+
+		line := w.buf.Next(idx + 1)
+		w.handleLine(line[:idx])
+	}
+
+	return len(p), nil
+}
+
+// Flush passes the remaining, not newline terminated output to the line handlers.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() == 0 {
+		return
+	}
+
+	w.handleLine(w.buf.Bytes())
+	w.buf.Reset()
+}
+
+func (w *lineWriter) handleLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	cleanLine := ansiEscapeRegexp.ReplaceAllString(string(line), "")
+	for _, handler := range w.handlers {
+		handler(cleanLine)
+	}
+}
+
+// redactionCounter counts the secrets masked by the redactingWriters of a Step run.
+type redactionCounter struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (c *redactionCounter) add(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count += n
+}
+
+func (c *redactionCounter) value() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// redactingWriter masks the secrets in the output written into it before passing it to the underlying writer.
+// A secret can be split across writes, so the end of a write is held back while it could be the beginning of a secret.
+type redactingWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets [][]byte
+	buf     []byte
+	counter *redactionCounter
+}
+
+func newRedactingWriter(w io.Writer, secrets []string, counter *redactionCounter) *redactingWriter {
+	var secretBytes [][]byte
+	for _, secret := range normalizeSecrets(secrets) {
+		secretBytes = append(secretBytes, []byte(secret))
+	}
+	return &redactingWriter{w: w, secrets: secretBytes, counter: counter}
+}
+
+// Write ...
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.secrets) == 0 {
+		return w.w.Write(p)
+	}
+
+	data := append(w.buf, p...)
+	out, rest := w.redact(data, false)
+	w.buf = append([]byte{}, rest...)
+
+	if len(out) > 0 {
+		if _, err := w.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush redacts and writes the held back output, no more output can complete a secret at this point.
+func (w *redactingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	out, _ := w.redact(w.buf, true)
+	w.buf = nil
+	_, err := w.w.Write(out)
+	return err
+}
+
+// redact returns the redacted data and the end of the data, which is the beginning of a secret.
+// If final is set, the data is not continued by later writes, so nothing is held back.
+func (w *redactingWriter) redact(data []byte, final bool) ([]byte, []byte) {
+	var out bytes.Buffer
+	masked := 0
+	start := 0
+	for i := 0; i < len(data); {
+		secret, partial := w.match(data[i:], final)
+		switch {
+		case secret != nil:
+			out.Write(data[start:i])
+			out.WriteString(redactedValue)
+			masked++
+			i += len(secret)
+			start = i
+		case partial:
+			out.Write(data[start:i])
+			w.counter.add(masked)
+			return out.Bytes(), data[i:]
+		default:
+			i++
+		}
+	}
+	out.Write(data[start:])
+	w.counter.add(masked)
+	return out.Bytes(), nil
+}
+
+// match returns the secret the data starts with, or whether the data is the beginning of a secret.
+// Secrets are ordered by length, so a secret containing a shorter one is waited for and masked as a whole.
+// If final is set, only whole secrets are matched.
+func (w *redactingWriter) match(data []byte, final bool) ([]byte, bool) {
+	for _, secret := range w.secrets {
+		if len(data) < len(secret) {
+			if !final && bytes.HasPrefix(secret, data) {
+				return nil, true
+			}
+			continue
+		}
+		if bytes.HasPrefix(data, secret) {
+			return secret, false
+		}
+	}
+	return nil, false
+}
+
+// prefixWriter writes the output written into it line by line, each line prefixed, to the underlying writer.
+// Whole lines are written at once, so that the output of parallel commands sharing the underlying writer does not interleave within lines.
+type prefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    bytes.Buffer
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: []byte(prefix)}
+}
+
+// Write ...
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx == -1 {
+			break
+		}
+
+		if err := w.writeLine(w.buf.Next(idx + 1)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the remaining, not newline terminated output as a line.
+func (w *prefixWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	line := append(append([]byte{}, w.buf.Bytes()...), '\n')
+	w.buf.Reset()
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	_, err := w.w.Write(append(append([]byte{}, w.prefix...), line...))
+	return err
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/appleauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenSecretsInOutput_WhenWritten_ThenSecretsAreMasked(t *testing.T) {
+	var out bytes.Buffer
+	counter := &redactionCounter{}
+	w := newRedactingWriter(&out, []string{"app-passw0rd", "session-cookie"}, counter)
+
+	_, err := w.Write([]byte("Password: app-passw0rd\nFASTLANE_SESSION=session-cookie app-passw0rd\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+
+	assert.Equal(t, "Password: [REDACTED]\nFASTLANE_SESSION=[REDACTED] [REDACTED]\n", out.String())
+	assert.Equal(t, 3, counter.value())
+}
+
+func Test_GivenSecretSplitAcrossWrites_WhenWritten_ThenSecretIsMasked(t *testing.T) {
+	var out bytes.Buffer
+	counter := &redactionCounter{}
+	w := newRedactingWriter(&out, []string{"app-passw0rd"}, counter)
+
+	for _, chunk := range []string{"Password: app", "-pass", "w0rd\n", "app-pa"} {
+		_, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "Password: [REDACTED]\n", out.String())
+
+	// the held back output, which turned out not to be a secret, is written on flush
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "Password: [REDACTED]\napp-pa", out.String())
+	assert.Equal(t, 1, counter.value())
+}
+
+func Test_GivenSecretContainingAnOther_WhenWritten_ThenLongerSecretIsMaskedAsAWhole(t *testing.T) {
+	var out bytes.Buffer
+	counter := &redactionCounter{}
+	w := newRedactingWriter(&out, []string{"token", "token-suffix"}, counter)
+
+	for _, chunk := range []string{"a token", "-suffix and a token\n"} {
+		_, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Flush())
+
+	assert.Equal(t, "a [REDACTED] and a [REDACTED]\n", out.String())
+	assert.Equal(t, 2, counter.value())
+}
+
+func Test_GivenNoSecrets_WhenWritten_ThenOutputIsNotChanged(t *testing.T) {
+	var out bytes.Buffer
+	counter := &redactionCounter{}
+	w := newRedactingWriter(&out, []string{"", "ab"}, counter)
+
+	_, err := w.Write([]byte("ab abc\n"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "ab abc\n", out.String())
+	assert.Equal(t, 0, counter.value())
+}
+
+func Test_GivenSecretAtEndOfOutput_WhenFlushed_ThenSecretIsMasked(t *testing.T) {
+	var out bytes.Buffer
+	counter := &redactionCounter{}
+	w := newRedactingWriter(&out, []string{"app-passw0rd"}, counter)
+
+	_, err := w.Write([]byte("Password: app-passw0rd"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+
+	assert.Equal(t, "Password: [REDACTED]", out.String())
+	assert.Equal(t, 1, counter.value())
+}
+
+func Test_GivenShorterSecretPrefixingLongerOne_WhenFlushed_ThenShorterSecretIsMasked(t *testing.T) {
+	var out bytes.Buffer
+	counter := &redactionCounter{}
+	w := newRedactingWriter(&out, []string{"abcdef", "abc"}, counter)
+
+	_, err := w.Write([]byte("pw=abcd"))
+	assert.NoError(t, err)
+	assert.Equal(t, "pw=", out.String())
+
+	assert.NoError(t, w.Flush())
+
+	assert.Equal(t, "pw=[REDACTED]d", out.String())
+	assert.Equal(t, 1, counter.value())
+}
+
+func Test_GivenAppleIDCredentials_WhenAuthSecretsCollected_ThenUsernameIsNotASecret(t *testing.T) {
+	credentials := appleauth.Credentials{AppleID: &appleauth.AppleID{
+		Username:            "dev@example.com",
+		Password:            "passw0rd",
+		Session:             "session-cookie",
+		AppSpecificPassword: "app-passw0rd",
+	}}
+
+	secrets := authSecretValues(fastlaneAuthEnvs(credentials, ""), credentials)
+	sort.Strings(secrets)
+
+	assert.NotContains(t, secrets, "dev@example.com")
+	assert.Equal(t, []string{"app-passw0rd", "passw0rd", "passw0rd", "passw0rd", "session-cookie"}, secrets)
+}
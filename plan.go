@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// planAPIKeyPath stands for the API Key JSON file in the plan, as the file is only written when fastlane runs.
+const planAPIKeyPath = "<temporary api_key.json>"
+
+// Plan prints the commands InstallDependencies and Run would execute, without executing them.
+func (f FastlaneRunner) Plan(dependenciesOpts EnsureDependenciesOpts, runOpts RunOpts) error {
+	f.logger.Println()
+	f.logger.Infof("Dry run: printing the execution plan, no commands are executed")
+	f.logger.Printf("Working directory: %s (%s)", runOpts.WorkDir, runOpts.WorkDirReason)
+
+	f.logger.Println()
+	f.logger.Infof("Apple Service authentication")
+	if runOpts.AuthSource == "" {
+		f.logger.Printf("No authentication data found, fastlane would run without Apple Service authentication")
+	} else {
+		f.logger.Printf("Selected source: %s", runOpts.AuthSource)
+	}
+
+	authEnvs := fastlaneAuthEnvs(runOpts.AuthCredentials, planAPIKeyPath)
+	if len(authEnvs) > 0 {
+		f.logger.Printf("Environment variables:")
+		var keys []string
+		for key := range authEnvs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := authEnvs[key]
+			if !nonSecretAuthEnvKeys[key] {
+				value = redactedValue
+			}
+			f.logger.Printf("- %s=%s", key, value)
+		}
+	}
+
+	f.warnDotenvAuthOverrides(runOpts.DotenvFiles, authEnvs)
+
+	if runOpts.FailOnInteractivePrompt {
+		f.logger.Println()
+		f.logger.Infof("Interactive prompts")
+		f.printNoninteractiveEnvs(noninteractiveEnvs(os.LookupEnv))
+		f.logger.Printf("fastlane would be terminated if it waited for an answer to a prompt for %s", interactivePromptStallTime)
+	}
+
+	if runOpts.EnvAllowlist != nil {
+		f.printCleanEnvironment(runOpts.EnvAllowlist, os.Environ())
+	}
+
+	if runOpts.IsolatedHome {
+		f.logger.Println()
+		f.logger.Infof("Isolated HOME")
+		f.logger.Printf("fastlane would run with a temporary HOME, linking: %s", strings.Join(runOpts.IsolatedHomeLinks, ", "))
+	}
+
+	f.logger.Println()
+	f.logger.Infof("Install dependencies")
+	if dependenciesOpts.UseBundler {
+		if dependenciesOpts.BundlePath != "" {
+			f.logger.Printf("Gems would be installed into %s", dependenciesOpts.BundlePath)
+		}
+		f.logger.Printf("The install would be skipped if bundler is installed and the installed gems satisfy the Gemfile.lock:")
+		f.logger.Donef("$ %s", f.bundlerVersionCommand(dependenciesOpts).PrintableCommandArgs())
+		f.logger.Donef("$ %s", f.bundleCheckCommand(dependenciesOpts).PrintableCommandArgs())
+	}
+	for _, step := range f.installSteps(dependenciesOpts) {
+		f.logger.Printf("%s", step.title)
+		for _, cmd := range step.cmds {
+			f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+		}
+	}
+	f.logger.Printf("Fastlane version")
+	f.logger.Donef("$ %s", f.fastlaneVersionCommand(dependenciesOpts).PrintableCommandArgs())
+
+	f.logger.Println()
+	f.logger.Infof("Run Fastlane")
+	secrets := append(append([]string{}, runOpts.Secrets...), authSecretValues(authEnvs, runOpts.AuthCredentials)...)
+	secrets = append(secrets, dotenvSecretValues(runOpts.DotenvFiles)...)
+	for _, laneOptions := range runOpts.Lanes {
+		laneSecrets := append(append([]string{}, secrets...), secretLaneOptionValues(laneOptions)...)
+		cmd := fastlaneLaneCommand(f.rbyFactory, runOpts, laneOptions, nil)
+		f.logger.Donef("$ %s", redactSecrets(cmd.PrintableCommandArgs(), laneSecrets))
+	}
+
+	f.logger.Println()
+	f.logger.Infof("Cache")
+	if !runOpts.EnableCache {
+		f.logger.Printf("Collecting cache is disabled")
+		return nil
+	}
+	includes, excludes := f.collectCacheItems(runOpts.WorkDir, runOpts.GemfilePath, runOpts.BundlePath)
+	if len(includes) == 0 && len(excludes) == 0 {
+		f.logger.Printf("No cache paths found")
+	}
+	for _, include := range includes {
+		f.logger.Printf("- include: %s", include)
+	}
+	for _, exclude := range excludes {
+		f.logger.Printf("- exclude: %s", exclude)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	pluginfileGemRegexp   = regexp.MustCompile(`^gem\s*\(?\s*["']([^"']+)["']`)
+	gemfileLockSpecRegexp = regexp.MustCompile(`^    ([^\s(]+) \(([^)]+)\)$`)
+)
+
+// fastlanePlugin is a plugin of the Pluginfile and its version resolved in the Gemfile.lock.
+type fastlanePlugin struct {
+	name    string
+	version string
+}
+
+// findPluginfile returns the path of the Pluginfile next to the Fastfile, or an empty path if there is no Pluginfile.
+func findPluginfile(workDir string) string {
+	fastfilePth, _ := findFastfile(workDir)
+	if fastfilePth == "" {
+		return ""
+	}
+	pth := filepath.Join(filepath.Dir(fastfilePth), "Pluginfile")
+	if info, err := os.Stat(pth); err != nil || info.IsDir() {
+		return ""
+	}
+	return pth
+}
+
+func parsePluginfile(content string) []string {
+	var plugins []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := pluginfileGemRegexp.FindStringSubmatch(line); match != nil {
+			plugins = append(plugins, match[1])
+		}
+	}
+	return plugins
+}
+
+// parseGemfileLockSpecs returns the versions of the gems resolved in the Gemfile.lock (from rubygems, git or path sources).
+func parseGemfileLockSpecs(content string) map[string]string {
+	specs := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		if match := gemfileLockSpecRegexp.FindStringSubmatch(strings.TrimRight(scanner.Text(), "\r")); match != nil {
+			specs[match[1]] = match[2]
+		}
+	}
+	return specs
+}
+
+// resolvePlugins returns the plugins with their resolved versions and the plugins missing from the Gemfile.lock.
+func resolvePlugins(plugins []string, specs map[string]string) ([]fastlanePlugin, []string) {
+	var resolved []fastlanePlugin
+	var missing []string
+	for _, plugin := range plugins {
+		version, ok := specs[plugin]
+		if !ok {
+			missing = append(missing, plugin)
+			continue
+		}
+		resolved = append(resolved, fastlanePlugin{name: plugin, version: version})
+	}
+	return resolved, missing
+}
+
+// evalGemfileInstruction returns the Gemfile lines loading the Pluginfile.
+func evalGemfileInstruction(gemfileDir, pluginfilePth string) string {
+	rel, err := filepath.Rel(gemfileDir, pluginfilePth)
+	if err != nil {
+		rel = pluginfilePth
+	}
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		parts = append(parts, fmt.Sprintf("'%s'", part))
+	}
+	return fmt.Sprintf("plugins_path = File.join(File.dirname(__FILE__), %s)\neval_gemfile(plugins_path) if File.exist?(plugins_path)", strings.Join(parts, ", "))
+}
+
+// validatePlugins checks that the plugins of the Pluginfile are resolved in the Gemfile.lock and prints their versions.
+func (f FastlaneRunner) validatePlugins(workDir, gemfilePath string) error {
+	pluginfilePth := findPluginfile(workDir)
+	if pluginfilePth == "" {
+		return nil
+	}
+
+	f.logger.Println()
+	f.logger.Infof("Checking fastlane plugins")
+
+	content, err := os.ReadFile(pluginfilePth)
+	if err != nil {
+		return fmt.Errorf("failed to read Pluginfile (%s): %w", pluginfilePth, err)
+	}
+	plugins := parsePluginfile(string(content))
+	if len(plugins) == 0 {
+		f.logger.Printf("No plugins in %s", pluginfilePth)
+		return nil
+	}
+
+	var lockContent []byte
+	if gemfilePath != "" {
+		lockContent, err = os.ReadFile(gemfileLockPath(gemfilePath))
+	}
+	if gemfilePath == "" || os.IsNotExist(err) {
+		f.logger.Warnf("%d plugin(s) found in %s, but there is no Gemfile.lock", len(plugins), pluginfilePth)
+		f.logger.Warnf("fastlane plugins should be installed with bundler, add a Gemfile loading the Pluginfile and commit the Gemfile.lock")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read Gemfile.lock: %w", err)
+	}
+
+	resolved, missing := resolvePlugins(plugins, parseGemfileLockSpecs(string(lockContent)))
+	f.printPlugins(resolved)
+
+	if len(missing) > 0 {
+		return fmt.Errorf(`plugin(s) of %s not found in the Gemfile.lock: %s
+The Gemfile should load the Pluginfile with the following lines (fastlane adds them when a plugin is installed with fastlane add_plugin):
+%s
+Run bundle install locally and commit the updated Gemfile.lock`, pluginfilePth, strings.Join(missing, ", "), evalGemfileInstruction(filepath.Dir(gemfilePath), pluginfilePth))
+	}
+
+	f.logger.Donef("All plugins of %s are resolved in the Gemfile.lock", pluginfilePth)
+	return nil
+}
+
+func (f FastlaneRunner) printPlugins(plugins []fastlanePlugin) {
+	if len(plugins) == 0 {
+		return
+	}
+
+	nameWidth := len("Plugin")
+	for _, plugin := range plugins {
+		nameWidth = maxInt(nameWidth, len(plugin.name))
+	}
+
+	f.logger.Printf("%-*s | %s", nameWidth, "Plugin", "Version")
+	f.logger.Printf("%s-|-%s", strings.Repeat("-", nameWidth), strings.Repeat("-", len("Version")))
+	for _, plugin := range plugins {
+		f.logger.Printf("%-*s | %s", nameWidth, plugin.name, plugin.version)
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPluginsGemfileLock = `GIT
+  remote: https://github.com/bitrise-io/fastlane-plugin-custom.git
+  revision: 1234567
+  specs:
+    fastlane-plugin-custom (0.1.0)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    fastlane (2.219.0)
+      addressable (>= 2.8, < 3.0.0)
+    fastlane-plugin-firebase_app_distribution (0.9.1)
+      google-apis-firebaseappdistribution_v1 (~> 0.3.0)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  fastlane
+  fastlane-plugin-custom!
+  fastlane-plugin-firebase_app_distribution
+`
+
+func Test_GivenPluginfile_WhenParsed_ThenGemNamesAreReturned(t *testing.T) {
+	content := `# Autogenerated by fastlane
+#
+# Ensure this file is checked in to source control!
+
+gem 'fastlane-plugin-firebase_app_distribution'
+gem "fastlane-plugin-custom", git: "https://github.com/bitrise-io/fastlane-plugin-custom.git"
+  gem('fastlane-plugin-versioning', '~> 0.5')
+# gem 'fastlane-plugin-disabled'
+`
+
+	assert.Equal(t, []string{
+		"fastlane-plugin-firebase_app_distribution",
+		"fastlane-plugin-custom",
+		"fastlane-plugin-versioning",
+	}, parsePluginfile(content))
+}
+
+func Test_GivenGemfileLock_WhenPluginsResolved_ThenMissingPluginsAreReturned(t *testing.T) {
+	specs := parseGemfileLockSpecs(testPluginsGemfileLock)
+
+	resolved, missing := resolvePlugins([]string{"fastlane-plugin-firebase_app_distribution", "fastlane-plugin-custom", "fastlane-plugin-versioning"}, specs)
+
+	assert.Equal(t, []fastlanePlugin{
+		{name: "fastlane-plugin-firebase_app_distribution", version: "0.9.1"},
+		{name: "fastlane-plugin-custom", version: "0.1.0"},
+	}, resolved)
+	assert.Equal(t, []string{"fastlane-plugin-versioning"}, missing)
+	assert.NotContains(t, specs, "addressable")
+}
+
+func Test_GivenPluginMissingFromGemfileLock_WhenValidated_ThenErrorExplainsEvalGemfile(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Fastfile"), "lane :test do\nend\n")
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Pluginfile"), "gem 'fastlane-plugin-versioning'\n")
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile"), "source 'https://rubygems.org'\ngem 'fastlane'\n")
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile.lock"), testPluginsGemfileLock)
+
+	step := FastlaneRunner{logger: log.NewLogger()}
+	err := step.validatePlugins(workDir, filepath.Join(workDir, "Gemfile"))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not found in the Gemfile.lock: fastlane-plugin-versioning")
+	assert.Contains(t, err.Error(), "plugins_path = File.join(File.dirname(__FILE__), 'fastlane', 'Pluginfile')\neval_gemfile(plugins_path) if File.exist?(plugins_path)")
+}
+
+func Test_GivenResolvedPlugins_WhenValidated_ThenReceiveNoError(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Fastfile"), "lane :test do\nend\n")
+	writeTestFileContent(t, filepath.Join(workDir, "fastlane", "Pluginfile"), "gem 'fastlane-plugin-custom'\n")
+	writeTestFileContent(t, filepath.Join(workDir, "Gemfile.lock"), testPluginsGemfileLock)
+
+	step := FastlaneRunner{logger: log.NewLogger()}
+	assert.NoError(t, step.validatePlugins(workDir, filepath.Join(workDir, "Gemfile")))
+
+	noLockDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(noLockDir, "fastlane", "Fastfile"), "lane :test do\nend\n")
+	writeTestFileContent(t, filepath.Join(noLockDir, "fastlane", "Pluginfile"), "gem 'fastlane-plugin-custom'\n")
+	assert.NoError(t, step.validatePlugins(noLockDir, ""))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+)
+
+// processGroupCommandFactory creates commands which are started in their own process group,
+// so that the whole process tree (fastlane, xcodebuild, gradle...) can be signalled at once.
+type processGroupCommandFactory struct {
+	envRepository env.Repository
+}
+
+func newProcessGroupCommandFactory(envRepository env.Repository) command.Factory {
+	return processGroupCommandFactory{envRepository: envRepository}
+}
+
+// Create ...
+func (f processGroupCommandFactory) Create(name string, args []string, opts *command.Opts) command.Command {
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if opts != nil {
+		cmd.Stdout = opts.Stdout
+		cmd.Stderr = opts.Stderr
+		cmd.Stdin = opts.Stdin
+		cmd.Env = append(f.envRepository.List(), opts.Env...)
+		cmd.Dir = opts.Dir
+	}
+	return &processGroupCommand{cmd: cmd}
+}
+
+type processGroupCommand struct {
+	cmd *exec.Cmd
+}
+
+// PrintableCommandArgs ...
+func (c *processGroupCommand) PrintableCommandArgs() string {
+	var args []string
+	for idx, arg := range c.cmd.Args {
+		if idx == 0 {
+			args = append(args, arg)
+		} else {
+			args = append(args, fmt.Sprintf("\"%s\"", arg))
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// Run ...
+func (c *processGroupCommand) Run() error {
+	return c.wrapError(c.cmd.Run())
+}
+
+// RunAndReturnExitCode ...
+func (c *processGroupCommand) RunAndReturnExitCode() (int, error) {
+	err := c.cmd.Run()
+	return c.cmd.ProcessState.ExitCode(), c.wrapError(err)
+}
+
+// RunAndReturnTrimmedOutput ...
+func (c *processGroupCommand) RunAndReturnTrimmedOutput() (string, error) {
+	out, err := c.cmd.Output()
+	return strings.TrimSpace(string(out)), c.wrapError(err)
+}
+
+// RunAndReturnTrimmedCombinedOutput ...
+func (c *processGroupCommand) RunAndReturnTrimmedCombinedOutput() (string, error) {
+	out, err := c.cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), c.wrapError(err)
+}
+
+// Start ...
+func (c *processGroupCommand) Start() error {
+	return c.cmd.Start()
+}
+
+// Wait ...
+func (c *processGroupCommand) Wait() error {
+	return c.wrapError(c.cmd.Wait())
+}
+
+// signal sends the signal to every process in the command's process group.
+func (c *processGroupCommand) signal(sig syscall.Signal) error {
+	if c.cmd.Process == nil {
+		return errors.New("process not started")
+	}
+	return syscall.Kill(-c.cmd.Process.Pid, sig)
+}
+
+func (c *processGroupCommand) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("command failed with exit status %d (%s): %w", exitErr.ExitCode(), c.PrintableCommandArgs(), errors.New("check the command's output for details"))
+	}
+	return fmt.Errorf("executing command failed (%s): %w", c.PrintableCommandArgs(), err)
+}
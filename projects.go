@@ -0,0 +1,413 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+)
+
+var projectSlugRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// project is a fastlane project of a monorepo, with its own work dir, lanes and fastlane version.
+type project struct {
+	name   string
+	config Config
+}
+
+// projectSpec is a line of the projects input: a directory or a glob pattern, and the lane to run in the matching directories.
+type projectSpec struct {
+	pattern string
+	lane    string
+}
+
+// parseProjectSpecs parses the `<directory or glob>[: <lane>]` lines, the lane input is used for the lines without a lane.
+func parseProjectSpecs(lines []string, defaultLane string) []projectSpec {
+	var specs []projectSpec
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, lane, _ := strings.Cut(line, ":")
+		spec := projectSpec{pattern: strings.TrimSpace(pattern), lane: strings.TrimSpace(lane)}
+		if spec.lane == "" {
+			spec.lane = defaultLane
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// resolveProjectDirs returns the directories matching the pattern.
+// Directories matched by a glob pattern are only kept if they contain a Fastfile.
+func (f FastlaneRunner) resolveProjectDirs(pattern string) ([]string, error) {
+	absPattern, err := f.pathModifier.AbsPath(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand project path (%s): %w", pattern, err)
+	}
+	matches, err := filepath.Glob(absPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project pattern (%s): %w", pattern, err)
+	}
+
+	isGlob := strings.ContainsAny(pattern, "*?[")
+	var dirs []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err != nil || !info.IsDir() {
+			continue
+		}
+		if fastfilePth, _ := findFastfile(match); isGlob && fastfilePth == "" {
+			continue
+		}
+		dirs = append(dirs, match)
+	}
+
+	if len(dirs) == 0 {
+		if isGlob {
+			return nil, fmt.Errorf("no directory with a Fastfile matches the project pattern (%s)", pattern)
+		}
+		return nil, fmt.Errorf("project directory (%s) does not exist", pattern)
+	}
+	return dirs, nil
+}
+
+// projectName returns the project dir relative to the base dir.
+func projectName(dir, baseDir string) string {
+	rel, err := filepath.Rel(baseDir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return dir
+	}
+	if rel == "." {
+		return filepath.Base(dir)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func projectSlug(name string) string {
+	return strings.Trim(projectSlugRegexp.ReplaceAllString(name, "_"), "_")
+}
+
+// projectFilePath inserts the project's slug before the extension of the file path, so that the projects do not overwrite each other's files.
+func projectFilePath(pth, slug string) string {
+	if pth == "" {
+		return ""
+	}
+	ext := filepath.Ext(pth)
+	return strings.TrimSuffix(pth, ext) + "_" + slug + ext
+}
+
+// processProjects resolves the projects input and processes the inputs depending on the work dir for each project,
+// the fastlane version is determined separately for each project, as the projects can pin different versions.
+func (f FastlaneRunner) processProjects(config Config) ([]project, error) {
+	f.logger.Println()
+	f.logger.Infof("Resolving projects")
+
+	currentDir, err := f.pathModifier.AbsPath(".")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get current dir, error: %s", err)
+	}
+
+	var projects []project
+	var lanes []string
+	dirs := map[string]bool{}
+	for _, spec := range parseProjectSpecs(config.InputProjects, config.Lane) {
+		matches, err := f.resolveProjectDirs(spec.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Input: %v", err)
+		}
+
+		for _, dir := range matches {
+			if dirs[dir] {
+				return nil, fmt.Errorf("Invalid Input: project directory (%s) is listed more than once", dir)
+			}
+			dirs[dir] = true
+
+			projectConfig := config
+			projectConfig.WorkDir = dir
+			projectConfig.WorkDirReason = fmt.Sprintf("matches the projects input (%s)", spec.pattern)
+			projects = append(projects, project{name: projectName(dir, currentDir), config: projectConfig})
+			lanes = append(lanes, spec.lane)
+		}
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("Invalid Input: no project specified")
+	}
+
+	for i, p := range projects {
+		f.logger.Printf("- %s: %s", p.name, strings.TrimSpace(strings.ReplaceAll(lanes[i], "\n", ", ")))
+	}
+
+	for i, p := range projects {
+		f.logger.Println()
+		f.logger.Infof("Processing project: %s", p.name)
+
+		projectConfig, err := f.processWorkDir(p.config, lanes[i])
+		if err != nil {
+			return nil, fmt.Errorf("Project %s: %w", p.name, err)
+		}
+		projects[i].config = projectConfig
+	}
+
+	return projects, nil
+}
+
+// prefixedLogger prefixes every logged line with the project name.
+type prefixedLogger struct {
+	log.Logger
+	prefix string
+}
+
+func (l prefixedLogger) prefixed(format string, v ...interface{}) string {
+	return l.prefix + strings.ReplaceAll(fmt.Sprintf(format, v...), "\n", "\n"+l.prefix)
+}
+
+// Infof ...
+func (l prefixedLogger) Infof(format string, v ...interface{}) {
+	l.Logger.Infof("%s", l.prefixed(format, v...))
+}
+
+// Warnf ...
+func (l prefixedLogger) Warnf(format string, v ...interface{}) {
+	l.Logger.Warnf("%s", l.prefixed(format, v...))
+}
+
+// Printf ...
+func (l prefixedLogger) Printf(format string, v ...interface{}) {
+	l.Logger.Printf("%s", l.prefixed(format, v...))
+}
+
+// Donef ...
+func (l prefixedLogger) Donef(format string, v ...interface{}) {
+	l.Logger.Donef("%s", l.prefixed(format, v...))
+}
+
+// Debugf ...
+func (l prefixedLogger) Debugf(format string, v ...interface{}) {
+	l.Logger.Debugf("%s", l.prefixed(format, v...))
+}
+
+// Errorf ...
+func (l prefixedLogger) Errorf(format string, v ...interface{}) {
+	l.Logger.Errorf("%s", l.prefixed(format, v...))
+}
+
+// TInfof ...
+func (l prefixedLogger) TInfof(format string, v ...interface{}) {
+	l.Logger.TInfof("%s", l.prefixed(format, v...))
+}
+
+// TWarnf ...
+func (l prefixedLogger) TWarnf(format string, v ...interface{}) {
+	l.Logger.TWarnf("%s", l.prefixed(format, v...))
+}
+
+// TPrintf ...
+func (l prefixedLogger) TPrintf(format string, v ...interface{}) {
+	l.Logger.TPrintf("%s", l.prefixed(format, v...))
+}
+
+// TDonef ...
+func (l prefixedLogger) TDonef(format string, v ...interface{}) {
+	l.Logger.TDonef("%s", l.prefixed(format, v...))
+}
+
+// TDebugf ...
+func (l prefixedLogger) TDebugf(format string, v ...interface{}) {
+	l.Logger.TDebugf("%s", l.prefixed(format, v...))
+}
+
+// TErrorf ...
+func (l prefixedLogger) TErrorf(format string, v ...interface{}) {
+	l.Logger.TErrorf("%s", l.prefixed(format, v...))
+}
+
+// Println ...
+func (l prefixedLogger) Println() {
+	l.Logger.Printf("%s", strings.TrimSpace(l.prefix))
+}
+
+// lockedOutputExporter serializes the output exports of the projects running in parallel.
+type lockedOutputExporter struct {
+	mu       *sync.Mutex
+	exporter outputExporter
+}
+
+// ExportOutput ...
+func (e lockedOutputExporter) ExportOutput(key, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.exporter.ExportOutput(key, value)
+}
+
+// projectRunner returns the FastlaneRunner of a project, which prefixes its log and command output with the project name,
+// and the function flushing the command output.
+func (f FastlaneRunner) projectRunner(name string, exporter outputExporter) (FastlaneRunner, func()) {
+	prefix := fmt.Sprintf("[%s] ", name)
+	stdout := newPrefixWriter(f.stdout, prefix)
+	stderr := newPrefixWriter(f.stderr, prefix)
+
+	runner := f
+	runner.logger = prefixedLogger{Logger: f.logger, prefix: prefix}
+	runner.stdout = stdout
+	runner.stderr = stderr
+	runner.outputExporter = exporter
+
+	return runner, func() {
+		for _, w := range []*prefixWriter{stdout, stderr} {
+			if err := w.Flush(); err != nil {
+				f.logger.Warnf("Failed to write the output of %s: %s", name, err)
+			}
+		}
+	}
+}
+
+// projectRunOptions returns the run options of the project, the files written to the deploy dir are separated by project.
+func (f FastlaneRunner) projectRunOptions(p project) RunOpts {
+	opts := createRunOptions(p.config)
+	slug := projectSlug(p.name)
+	if opts.DeployDir != "" {
+		opts.DeployDir = filepath.Join(opts.DeployDir, slug)
+	}
+	opts.RunSummaryPath = projectFilePath(opts.RunSummaryPath, slug)
+	// the dependencies of all the projects are committed to the cache at once
+	opts.EnableCache = false
+	return opts
+}
+
+type projectResult struct {
+	name            string
+	fastlaneVersion string
+	duration        time.Duration
+	err             error
+}
+
+// PlanProjects prints the execution plan of each project.
+func (f FastlaneRunner) PlanProjects(config Config) error {
+	f.logger.Println()
+	f.logger.Infof("Dry run: %d project(s), run in parallel: %t", len(config.Projects), config.ParallelProjects)
+
+	for _, p := range config.Projects {
+		runner, flush := f.projectRunner(p.name, f.outputExporter)
+		err := runner.Plan(createDependenciesOptions(p.config), f.projectRunOptions(p))
+		flush()
+		if err != nil {
+			return fmt.Errorf("Project %s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// RunProjects installs the dependencies and runs the lanes of each project, a failing project does not stop the others.
+// The dependencies are installed one project after the other, as the projects share the Ruby installation and its gem dir,
+// the lanes run in parallel if enabled.
+func (f FastlaneRunner) RunProjects(config Config) error {
+	exporter := lockedOutputExporter{mu: &sync.Mutex{}, exporter: f.outputExporter}
+	results := make([]projectResult, len(config.Projects))
+	runOpts := make([]RunOpts, len(config.Projects))
+
+	for i, p := range config.Projects {
+		results[i] = projectResult{name: p.name, fastlaneVersion: fastlaneVersionDescription(p.config.GemVersions)}
+
+		f.logger.Println()
+		f.logger.Infof("Installing dependencies of %s", p.name)
+
+		runner, flush := f.projectRunner(p.name, exporter)
+		startTime := time.Now()
+		dependencies, err := runner.InstallDependencies(createDependenciesOptions(p.config))
+		flush()
+		results[i].duration = time.Since(startTime)
+		if err != nil {
+			results[i].err = fmt.Errorf("failed to install dependencies: %w", err)
+			continue
+		}
+
+		runOpts[i] = f.projectRunOptions(p)
+		runOpts[i].RubyVersion = dependencies.RubyVersion
+		if runOpts[i].DeployDir != "" {
+			if err := os.MkdirAll(runOpts[i].DeployDir, 0755); err != nil {
+				f.logger.Warnf("Failed to create the deploy dir of %s: %s", p.name, err)
+				runOpts[i].DeployDir = ""
+			}
+		}
+	}
+
+	runProject := func(i int) {
+		runner, flush := f.projectRunner(config.Projects[i].name, exporter)
+		startTime := time.Now()
+		err := runner.Run(runOpts[i])
+		flush()
+		results[i].duration += time.Since(startTime)
+		results[i].err = err
+	}
+
+	f.logger.Println()
+	if config.ParallelProjects {
+		f.logger.Infof("Running the lanes of the projects in parallel")
+		var wg sync.WaitGroup
+		for i := range config.Projects {
+			if results[i].err != nil {
+				continue
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				runProject(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		f.logger.Infof("Running the lanes of the projects")
+		for i := range config.Projects {
+			if results[i].err != nil {
+				continue
+			}
+			runProject(i)
+		}
+	}
+
+	f.printProjectResults(results)
+
+	var failed []projectResult
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) > 0 {
+		first := failed[0]
+		return newStepError(categoryOf(first.err, errorCategoryLane), fmt.Errorf("%d of %d project(s) failed, %s: %w", len(failed), len(results), first.name, first.err))
+	}
+
+	if config.EnableCache {
+		f.commitCache(runOpts...)
+	}
+
+	return nil
+}
+
+func fastlaneVersionDescription(versions gemVersions) string {
+	if versions.fastlane.Found && versions.fastlane.Version != "" {
+		return "fastlane " + versions.fastlane.Version
+	}
+	return "system installed fastlane"
+}
+
+func (f FastlaneRunner) printProjectResults(results []projectResult) {
+	f.logger.Println()
+	f.logger.Infof("Project results")
+
+	for _, result := range results {
+		if result.err != nil {
+			f.logger.Errorf("- %s: failed, %s error (%s, %s)", result.name, categoryOf(result.err, errorCategoryLane), result.duration.Round(time.Second), result.fastlaneVersion)
+			continue
+		}
+		f.logger.Donef("- %s: succeeded (%s, %s)", result.name, result.duration.Round(time.Second), result.fastlaneVersion)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenProjectsInput_WhenParsed_ThenLaneInputIsUsedForLinesWithoutLane(t *testing.T) {
+	specs := parseProjectSpecs([]string{
+		"apps/ios: ios beta",
+		"",
+		"# apps/legacy: test",
+		" apps/android : android deploy version:1.2 ",
+		"apps/watch*",
+	}, "test")
+
+	assert.Equal(t, []projectSpec{
+		{pattern: "apps/ios", lane: "ios beta"},
+		{pattern: "apps/android", lane: "android deploy version:1.2"},
+		{pattern: "apps/watch*", lane: "test"},
+	}, specs)
+}
+
+func Test_GivenMonorepo_WhenProjectDirsResolved_ThenGlobOnlyMatchesFastlaneProjects(t *testing.T) {
+	root := t.TempDir()
+	writeTestFileContent(t, filepath.Join(root, "apps", "ios", "fastlane", "Fastfile"), "lane :beta do\nend\n")
+	writeTestFileContent(t, filepath.Join(root, "apps", "android", "fastlane", "Fastfile"), "lane :deploy do\nend\n")
+	writeTestFileContent(t, filepath.Join(root, "apps", "shared", "README.md"), "shared code")
+
+	step := FastlaneRunner{pathModifier: pathutil.NewPathModifier()}
+
+	dirs, err := step.resolveProjectDirs(filepath.Join(root, "apps", "*"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "apps", "android"), filepath.Join(root, "apps", "ios")}, dirs)
+
+	dirs, err = step.resolveProjectDirs(filepath.Join(root, "apps", "shared"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "apps", "shared")}, dirs)
+
+	_, err = step.resolveProjectDirs(filepath.Join(root, "apps", "web"))
+	assert.EqualError(t, err, "project directory ("+filepath.Join(root, "apps", "web")+") does not exist")
+
+	_, err = step.resolveProjectDirs(filepath.Join(root, "apps", "w*"))
+	assert.EqualError(t, err, "no directory with a Fastfile matches the project pattern ("+filepath.Join(root, "apps", "w*")+")")
+}
+
+func Test_GivenProjectDir_WhenNamed_ThenNameIsRelativeToBaseDir(t *testing.T) {
+	assert.Equal(t, "apps/ios", projectName("/src/apps/ios", "/src"))
+	assert.Equal(t, "src", projectName("/src", "/src"))
+	assert.Equal(t, "/other/app", projectName("/other/app", "/src"))
+
+	assert.Equal(t, "apps_ios", projectSlug("apps/ios"))
+	assert.Equal(t, "/deploy/fastlane_run_summary_apps_ios.md", projectFilePath("/deploy/fastlane_run_summary.md", "apps_ios"))
+	assert.Equal(t, "", projectFilePath("", "apps_ios"))
+}
+
+func Test_GivenProject_WhenRunOptionsCreated_ThenDeployFilesAreSeparated(t *testing.T) {
+	config := Config{WorkDir: "/src/apps/ios", Lanes: [][]string{{"ios", "beta"}}}
+	config.DeployDir = "/deploy"
+	config.RunSummaryPath = "/deploy/fastlane_run_summary.md"
+	config.EnableCache = true
+
+	opts := FastlaneRunner{}.projectRunOptions(project{name: "apps/ios", config: config})
+
+	assert.Equal(t, "/src/apps/ios", opts.WorkDir)
+	assert.Equal(t, "/deploy/apps_ios", opts.DeployDir)
+	assert.Equal(t, "/deploy/fastlane_run_summary_apps_ios.md", opts.RunSummaryPath)
+	assert.False(t, opts.EnableCache)
+}
+
+func Test_GivenProjectRunner_WhenOutputWritten_ThenLinesArePrefixed(t *testing.T) {
+	var out bytes.Buffer
+	step := FastlaneRunner{logger: log.NewLogger(), stdout: &out, stderr: &out}
+
+	runner, flush := step.projectRunner("apps/ios", fakeOutputExporter{})
+	_, err := runner.stdout.Write([]byte("Driving the lane 'ios beta'\n[13:37:00]: gym"))
+	assert.NoError(t, err)
+	assert.Equal(t, "[apps/ios] Driving the lane 'ios beta'\n", out.String())
+
+	flush()
+	assert.Equal(t, "[apps/ios] Driving the lane 'ios beta'\n[apps/ios] [13:37:00]: gym\n", out.String())
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// interactivePromptStallTime is the time fastlane has to print more output after an interactive prompt,
+// before it is considered to be waiting for input.
+const interactivePromptStallTime = 10 * time.Second
+
+// promptWatcherMaxLineLength limits the size of the last output line kept by the promptWatcher.
+const promptWatcherMaxLineLength = 4096
+
+// fastlaneTimestampRegexp matches the timestamp fastlane prefixes its messages (and prompts) with.
+var fastlaneTimestampRegexp = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2}\]:\s*`)
+
+// interactivePrompt is a known question of fastlane or Spaceship, which can not be answered on CI.
+type interactivePrompt struct {
+	name     string
+	subject  string
+	advice   string
+	patterns []*regexp.Regexp
+}
+
+var interactivePrompts = []interactivePrompt{
+	{
+		name:    "two_factor_code",
+		subject: "a 2FA code",
+		advice:  "use an API key or an app-specific password",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)please enter the \d+ digit code`),
+			regexp.MustCompile(`(?i)enter the code you received`),
+			regexp.MustCompile(`(?i)please select a trusted phone number`),
+		},
+	},
+	{
+		name:    "credentials",
+		subject: "credentials",
+		advice:  "set them in the Step inputs or as Secret Environment Variables (for example FASTLANE_PASSWORD or MATCH_PASSWORD)",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)^password( \(for .+\))?:\s*$`),
+			regexp.MustCompile(`(?i)^(apple id )?username:\s*$`),
+			regexp.MustCompile(`(?i)passphrase for (the )?match`),
+			regexp.MustCompile(`(?i)enter the passphrase`),
+		},
+	},
+	{
+		name:    "team_selection",
+		subject: "a team selection",
+		advice:  "set the team_id or team_name option of the action (or the FASTLANE_TEAM_ID and FASTLANE_ITC_TEAM_ID Environment Variables)",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)multiple .*teams found`),
+			regexp.MustCompile(`(?i)enter the number of the team`),
+			regexp.MustCompile(`(?i)select (a|the|your) team`),
+		},
+	},
+	{
+		name:    "confirmation",
+		subject: "a confirmation",
+		advice:  "set the action's option skipping the confirmation (for example force: true or skip_confirmation: true)",
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\((y/n|yes/no)\)\s*[?:]?\s*$`),
+		},
+	},
+}
+
+// noninteractiveEnvDefaults are set for fastlane (unless already set), so that it fails instead of asking questions:
+// with CI set, fastlane's UI is non-interactive.
+var noninteractiveEnvDefaults = map[string]string{
+	"CI":                           "true",
+	"FASTLANE_SKIP_UPDATE_CHECK":   "true",
+	"FASTLANE_HIDE_CHANGELOG":      "true",
+	"FASTLANE_DONT_STORE_PASSWORD": "1",
+	"SPACESHIP_SKIP_2FA_UPGRADE":   "1",
+}
+
+// noninteractiveEnvs returns the non-interactive defaults, which are not set in the environment, in KEY=value format.
+func noninteractiveEnvs(lookupEnv func(string) (string, bool)) []string {
+	var envs []string
+	for key, value := range noninteractiveEnvDefaults {
+		if _, set := lookupEnv(key); set {
+			continue
+		}
+		envs = append(envs, key+"="+value)
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+func matchInteractivePrompt(line string) *interactivePrompt {
+	for i, prompt := range interactivePrompts {
+		for _, pattern := range prompt.patterns {
+			if pattern.MatchString(line) {
+				return &interactivePrompts[i]
+			}
+		}
+	}
+	return nil
+}
+
+// interactivePromptError is returned when fastlane was terminated, because it was waiting for input.
+type interactivePromptError struct {
+	prompt *interactivePrompt
+	line   string
+}
+
+func (e *interactivePromptError) Error() string {
+	return fmt.Sprintf("fastlane asked for %s: %s", e.prompt.subject, e.prompt.advice)
+}
+
+// promptWatcher checks whether the last line of the output written into it (which is usually not newline terminated) is an interactive prompt.
+type promptWatcher struct {
+	mu     sync.Mutex
+	buf    []byte
+	prompt *interactivePrompt
+	line   string
+}
+
+func newPromptWatcher() *promptWatcher {
+	return &promptWatcher{}
+}
+
+// Write ...
+func (w *promptWatcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// only the unterminated last line can be a prompt waiting for an answer, a completed line is not
+	w.buf = append(w.buf, p...)
+	start := bytes.LastIndexByte(w.buf, '\n') + 1
+	if len(w.buf)-start > promptWatcherMaxLineLength {
+		start = len(w.buf) - promptWatcherMaxLineLength
+	}
+	w.buf = append([]byte{}, w.buf[start:]...)
+
+	line := strings.TrimSpace(ansiEscapeRegexp.ReplaceAllString(string(w.buf), ""))
+	line = fastlaneTimestampRegexp.ReplaceAllString(line, "")
+	w.prompt = matchInteractivePrompt(line)
+	w.line = line
+
+	return len(p), nil
+}
+
+// waitingPrompt returns the prompt fastlane is waiting on: the last line of the output is a prompt,
+// and fastlane has not printed anything since the stall time.
+func (w *promptWatcher) waitingPrompt(idleTime time.Duration) *interactivePromptError {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.prompt == nil || idleTime < interactivePromptStallTime {
+		return nil
+	}
+	return &interactivePromptError{prompt: w.prompt, line: w.line}
+}
+
+func (f FastlaneRunner) printNoninteractiveEnvs(envs []string) {
+	if len(envs) == 0 {
+		return
+	}
+	f.logger.Printf("Running fastlane non-interactively with: %s", strings.Join(envs, " "))
+}
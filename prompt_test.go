@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenPromptAsLastOutputLine_WhenFastlaneStalls_ThenWaitingPromptIsReturned(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		prompt string
+	}{
+		{
+			name:   "2FA code",
+			output: "Two-factor Authentication (6 digits code) is enabled for account 'dev@example.com'\n\x1b[33mPlease enter the 6 digit code you received at +36 •• ••• ••11:\x1b[0m ",
+			prompt: "two_factor_code",
+		},
+		{
+			name:   "password",
+			output: "[13:37:00]: -----------------------------------------\n[13:37:00]: Password (for dev@example.com): ",
+			prompt: "credentials",
+		},
+		{
+			name:   "match passphrase",
+			output: "[13:37:00]: Enter the passphrase that should be used to encrypt/decrypt your certificates\n[13:37:00]: Enter the passphrase that should be used to encrypt/decrypt your certificates: ",
+			prompt: "credentials",
+		},
+		{
+			name:   "team selection",
+			output: "Multiple teams found on the Developer Portal, please enter the number of the team you want to use: ",
+			prompt: "team_selection",
+		},
+		{
+			name:   "confirmation",
+			output: "[13:37:00]: Do you want to continue? (y/n) ",
+			prompt: "confirmation",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watcher := newPromptWatcher()
+			// the output arrives in chunks, not split at line boundaries
+			for i := 0; i < len(tt.output); i += 7 {
+				_, err := watcher.Write([]byte(tt.output[i:minInt(i+7, len(tt.output))]))
+				assert.NoError(t, err)
+			}
+
+			assert.Nil(t, watcher.waitingPrompt(time.Second))
+			promptErr := watcher.waitingPrompt(interactivePromptStallTime)
+			if assert.NotNil(t, promptErr) {
+				assert.Equal(t, tt.prompt, promptErr.prompt.name)
+			}
+		})
+	}
+}
+
+func Test_GivenPromptFollowedByOutput_WhenWatched_ThenNoPromptIsWaiting(t *testing.T) {
+	watcher := newPromptWatcher()
+	_, err := watcher.Write([]byte("[13:37:00]: Do you want to continue? (y/n)\n[13:37:01]: Continuing with the default answer\n"))
+	assert.NoError(t, err)
+
+	assert.Nil(t, watcher.waitingPrompt(time.Hour))
+}
+
+func Test_GivenCompletedPromptLine_WhenFastlaneIsSilent_ThenNoPromptIsWaiting(t *testing.T) {
+	watcher := newPromptWatcher()
+	_, err := watcher.Write([]byte("[13:37:00]: Password (for dev@example.com): \n"))
+	assert.NoError(t, err)
+
+	assert.Nil(t, watcher.waitingPrompt(interactivePromptStallTime))
+}
+
+func Test_Given2FAPrompt_WhenErrorReported_ThenAdviceIsIncluded(t *testing.T) {
+	err := &interactivePromptError{prompt: matchInteractivePrompt("Please enter the 6 digit code:"), line: "Please enter the 6 digit code:"}
+
+	assert.EqualError(t, err, "fastlane asked for a 2FA code: use an API key or an app-specific password")
+}
+
+func Test_GivenEnvironment_WhenNoninteractiveEnvsCreated_ThenSetVariablesAreKept(t *testing.T) {
+	environ := map[string]string{"CI": "false", "SPACESHIP_SKIP_2FA_UPGRADE": ""}
+	lookup := func(key string) (string, bool) {
+		value, ok := environ[key]
+		return value, ok
+	}
+
+	assert.Equal(t, []string{
+		"FASTLANE_DONT_STORE_PASSWORD=1",
+		"FASTLANE_HIDE_CHANGELOG=true",
+		"FASTLANE_SKIP_UPDATE_CHECK=true",
+	}, noninteractiveEnvs(lookup))
+}
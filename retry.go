@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxRetryBackoff caps the time waited before a retry.
+const maxRetryBackoff = 10 * time.Minute
+
+// transientErrorPatterns match fastlane output lines of known flaky failures (Apple service outages, network issues, rate limiting),
+// a lane failing with one of these is worth retrying.
+var transientErrorPatterns = []string{
+	`The request timed out`,
+	`Net::ReadTimeout`,
+	`Net::OpenTimeout`,
+	`Faraday::ConnectionFailed`,
+	`Faraday::TimeoutError`,
+	`Errno::ECONNRESET`,
+	`Errno::ETIMEDOUT`,
+	`SSL_connect returned=1`,
+	`Spaceship::InternalServerError`,
+	`Spaceship::BadGatewayError`,
+	`Spaceship::GatewayTimeoutError`,
+	`Spaceship::TooManyRequestsError`,
+	`\b(500 Internal Server Error|502 Bad Gateway|503 Service Unavailable|504 Gateway Time-?out)\b`,
+	`\b429 Too Many Requests\b`,
+	`(?i)rate limit exceeded`,
+	`(?i)Apple's servers? (is|are) (currently )?(unavailable|down|having issues)`,
+}
+
+type retryPolicy struct {
+	maxAttempts int
+	waitTime    time.Duration
+	patterns    []*regexp.Regexp
+}
+
+func newRetryPolicy(maxAttempts int, waitTime time.Duration, additionalPatterns []string) (retryPolicy, error) {
+	var patterns []*regexp.Regexp
+	for _, pattern := range append(transientErrorPatterns, additionalPatterns...) {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		exp, err := regexp.Compile(pattern)
+		if err != nil {
+			return retryPolicy{}, fmt.Errorf("invalid retry pattern (%s): %w", pattern, err)
+		}
+		patterns = append(patterns, exp)
+	}
+
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return retryPolicy{
+		maxAttempts: maxAttempts,
+		waitTime:    waitTime,
+		patterns:    patterns,
+	}, nil
+}
+
+// backoff returns the time to wait before the given (1-based) retry, the wait time doubles with every retry
+// up to maxRetryBackoff.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	if p.waitTime <= 0 {
+		return 0
+	}
+
+	wait := p.waitTime
+	for i := 1; i < retry && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryBackoff {
+		wait = maxRetryBackoff
+	}
+	return wait
+}
+
+// transientErrorMatcher records the first output line matching any of the transient error patterns.
+type transientErrorMatcher struct {
+	patterns []*regexp.Regexp
+	match    string
+}
+
+func newTransientErrorMatcher(patterns []*regexp.Regexp) *transientErrorMatcher {
+	return &transientErrorMatcher{patterns: patterns}
+}
+
+func (m *transientErrorMatcher) handleLine(line string) {
+	if m.match != "" {
+		return
+	}
+
+	for _, pattern := range m.patterns {
+		if pattern.MatchString(line) {
+			m.match = strings.TrimSpace(line)
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenTransientErrorInOutput_WhenMatched_ThenRecordFirstMatchingLine(t *testing.T) {
+	policy, err := newRetryPolicy(3, time.Second, nil)
+	assert.NoError(t, err)
+	matcher := newTransientErrorMatcher(policy.patterns)
+	writer := newLineWriter(matcher.handleLine)
+
+	_, err = writer.Write([]byte("[12:00:00]: \x1b[31mNet::Read"))
+	assert.NoError(t, err)
+	_, err = writer.Write([]byte("Timeout\x1b[0m\nThe request timed out.\n"))
+	assert.NoError(t, err)
+	writer.Flush()
+
+	assert.Equal(t, "[12:00:00]: Net::ReadTimeout", matcher.match)
+}
+
+func Test_GivenCustomPattern_WhenMatched_ThenRecordMatchingLine(t *testing.T) {
+	policy, err := newRetryPolicy(2, time.Second, []string{"", `Simulator .* failed to boot`})
+	assert.NoError(t, err)
+	matcher := newTransientErrorMatcher(policy.patterns)
+	writer := newLineWriter(matcher.handleLine)
+
+	_, err = writer.Write([]byte("Simulator iPhone 15 failed to boot"))
+	assert.NoError(t, err)
+	writer.Flush()
+
+	assert.Equal(t, "Simulator iPhone 15 failed to boot", matcher.match)
+}
+
+func Test_GivenNonTransientError_WhenMatched_ThenNoMatch(t *testing.T) {
+	policy, err := newRetryPolicy(2, time.Second, nil)
+	assert.NoError(t, err)
+	matcher := newTransientErrorMatcher(policy.patterns)
+	writer := newLineWriter(matcher.handleLine)
+
+	_, err = writer.Write([]byte("Could not find lane 'ios betta'. Available lanes: ios beta\n"))
+	assert.NoError(t, err)
+	writer.Flush()
+
+	assert.Empty(t, matcher.match)
+}
+
+func Test_GivenInvalidPattern_WhenNewRetryPolicy_ThenReceiveError(t *testing.T) {
+	_, err := newRetryPolicy(2, time.Second, []string{"("})
+
+	assert.Error(t, err)
+}
+
+func Test_GivenRetryPolicy_WhenBackoff_ThenWaitTimeDoubles(t *testing.T) {
+	policy, err := newRetryPolicy(4, 10*time.Second, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 10*time.Second, policy.backoff(1))
+	assert.Equal(t, 20*time.Second, policy.backoff(2))
+	assert.Equal(t, 40*time.Second, policy.backoff(3))
+}
+
+func Test_GivenManyRetriesOrLongWaitTime_WhenBackoff_ThenWaitTimeIsCapped(t *testing.T) {
+	policy, err := newRetryPolicy(100, 10*time.Second, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 320*time.Second, policy.backoff(6))
+	assert.Equal(t, maxRetryBackoff, policy.backoff(7))
+	assert.Equal(t, maxRetryBackoff, policy.backoff(64))
+	assert.Equal(t, maxRetryBackoff, policy.backoff(99))
+
+	policy, err = newRetryPolicy(3, 24*time.Hour, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, maxRetryBackoff, policy.backoff(1))
+	assert.Equal(t, maxRetryBackoff, policy.backoff(2))
+
+	policy, err = newRetryPolicy(3, 0, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Duration(0), policy.backoff(2))
+}
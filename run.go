@@ -5,27 +5,59 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/command/gems"
 	"github.com/bitrise-io/go-steputils/v2/ruby"
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/pathutil"
 	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
 	"github.com/bitrise-io/go-xcode/appleauth"
 )
 
 // RunOpts ...
 type RunOpts struct {
-	WorkDir         string
-	AuthCredentials appleauth.Credentials
-	LaneOptions     []string
-	UseBundler      bool
-	GemVersions     gemVersions
-	EnableCache     bool
+	WorkDir                 string
+	WorkDirReason           string
+	AuthCredentials         appleauth.Credentials
+	AuthSource              string
+	Lanes                   [][]string
+	DotenvFiles             []dotenvFile
+	ContinueOnLaneFailure   bool
+	RetryPolicy             retryPolicy
+	EnvAllowlist            *envAllowlist
+	IsolatedHome            bool
+	IsolatedHomeLinks       []string
+	Timeouts                timeoutOpts
+	FailOnInteractivePrompt bool
+	LaneContextKeys         []string
+	ArtifactExport          string
+	Secrets                 []string
+	BuildLogCollection      string
+	RunSummaryPath          string
+	DeployDir               string
+	UseBundler              bool
+	GemVersions             gemVersions
+	GemfilePath             string
+	BundlePath              string
+	RubyVersion             string
+	EnableCache             bool
+}
+
+type laneResult struct {
+	lane          string
+	laneOptions   []string
+	duration      time.Duration
+	err           error
+	skipped       bool
+	classifier    *failureClassifier
+	actionSummary *actionSummaryParser
 }
 
 // Run ...
@@ -37,7 +69,7 @@ func (f FastlaneRunner) Run(opts RunOpts) error {
 	var envs []string
 	authEnvs, err := FastlaneAuthParams(opts.AuthCredentials)
 	if err != nil {
-		return fmt.Errorf("Failed to set up Fastlane authentication parameters: %v", err)
+		return newStepError(errorCategoryAuth, fmt.Errorf("Failed to set up Fastlane authentication parameters: %v", err))
 	}
 	var globallySetAuthEnvs []string
 	for envKey, envValue := range authEnvs {
@@ -47,10 +79,23 @@ func (f FastlaneRunner) Run(opts RunOpts) error {
 
 		envs = append(envs, fmt.Sprintf("%s=%s", envKey, envValue))
 	}
+	secrets := append(append([]string{}, opts.Secrets...), authSecretValues(authEnvs, opts.AuthCredentials)...)
+	secrets = append(secrets, dotenvSecretValues(opts.DotenvFiles)...)
 	if len(globallySetAuthEnvs) != 0 {
 		f.logger.Warnf("Fastlane authentication-related environment varibale(s) (%s) are set, overriding.", globallySetAuthEnvs)
 		f.logger.Infof("To stop overriding authentication-related environment variables, please set Bitrise Apple Developer Connection input to 'off' and leave authentication-related inputs empty.")
 	}
+	f.warnDotenvAuthOverrides(opts.DotenvFiles, authEnvs)
+
+	if opts.UseBundler {
+		envs = append(envs, bundlerEnvs(opts.GemfilePath, opts.BundlePath)...)
+	}
+
+	if opts.FailOnInteractivePrompt {
+		noninteractive := noninteractiveEnvs(os.LookupEnv)
+		f.printNoninteractiveEnvs(noninteractive)
+		envs = append(envs, noninteractive...)
+	}
 
 	buildlogPth := ""
 	if tempDir, err := pathutil.NormalizedOSTempDirPath("fastlane_logs"); err != nil {
@@ -60,67 +105,279 @@ func (f FastlaneRunner) Run(opts RunOpts) error {
 		envs = append(envs, "FL_BUILDLOG_PATH="+buildlogPth)
 	}
 
-	name := "fastlane"
-	args := opts.LaneOptions
-	options := &command.Opts{
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-		Dir:    opts.WorkDir,
-		Env:    append(os.Environ(), envs...),
-	}
-	var cmd command.Command
-	if opts.UseBundler {
-		cmd = f.rbyFactory.CreateBundleExec(name, args, opts.GemVersions.bundler.Version, options)
+	var laneContextCapture *laneContextCapture
+	if tempDir, err := pathutil.NormalizedOSTempDirPath("lane_context"); err != nil {
+		f.logger.Warnf("Failed to create temp dir for capturing lane_context, error: %s", err)
+	} else if capture, err := newLaneContextCapture(tempDir); err != nil {
+		f.logger.Warnf("%s", err)
 	} else {
-		cmd = f.rbyFactory.Create(name, args, options)
+		laneContextCapture = &capture
+		envs = append(envs, capture.envs()...)
 	}
 
-	f.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+	if opts.EnvAllowlist != nil {
+		f.printCleanEnvironment(opts.EnvAllowlist, os.Environ())
+	}
+
+	if opts.IsolatedHome {
+		realHome, err := os.UserHomeDir()
+		if err != nil {
+			return newStepError(errorCategoryConfig, fmt.Errorf("Failed to get HOME dir: %w", err))
+		}
+		home, err := newIsolatedHome(realHome, opts.IsolatedHomeLinks)
+		if home != nil {
+			defer f.cleanupIsolatedHome(home)
+		}
+		if err != nil {
+			return newStepError(errorCategoryConfig, err)
+		}
+		f.printIsolatedHome(home)
+		envs = append(envs, home.envs()...)
+	}
+
+	deployDir := opts.DeployDir
+
+	var snapshot *artifactSnapshot
+	if opts.ArtifactExport != artifactExportOff {
+		s, err := newArtifactSnapshot(artifactSearchDirs(opts.WorkDir, deployDir))
+		if err != nil {
+			f.logger.Warnf("Failed to collect existing build artifacts, skipping artifact export: %s", err)
+		} else {
+			snapshot = &s
+		}
+	}
+
+	var results []laneResult
+	var fastlaneErr error
+	laneContext := map[string]interface{}{}
+	tail := newOutputTail(outputTailMaxLines)
+	redactions := &redactionCounter{}
+	for _, laneOptions := range opts.Lanes {
+		lane := strings.Join(laneOptions, " ")
+		if fastlaneErr != nil && !opts.ContinueOnLaneFailure {
+			results = append(results, laneResult{lane: lane, laneOptions: laneOptions, skipped: true})
+			continue
+		}
+
+		if len(opts.Lanes) > 1 {
+			f.logger.Println()
+			f.logger.Infof("Run lane: %s", lane)
+		}
+
+		classifier := newFailureClassifier()
+		actionSummary := newActionSummaryParser()
+		startTime := time.Now()
+		err := f.runLane(opts, laneOptions, envs, secrets, redactions, classifier.handleLine, actionSummary.handleLine, tail.handleLine)
+		results = append(results, laneResult{lane: lane, laneOptions: laneOptions, duration: time.Since(startTime), err: err, classifier: classifier, actionSummary: actionSummary})
+		if err != nil && fastlaneErr == nil {
+			fastlaneErr = err
+		}
+
+		if err == nil && laneContextCapture != nil {
+			values, err := laneContextCapture.read()
+			if err != nil {
+				f.logger.Warnf("Failed to read lane_context: %s", err)
+			}
+			for key, value := range values {
+				laneContext[key] = value
+			}
+		}
+	}
+
+	f.printLaneResults(results)
+	f.logger.Printf("Masked %d secret occurrence(s) in the fastlane output", redactions.value())
 
-	deployDir := os.Getenv("BITRISE_DEPLOY_DIR")
 	if deployDir == "" {
 		f.logger.Warnf("No BITRISE_DEPLOY_DIR found")
 	}
 	deployPth := filepath.Join(deployDir, "fastlane_env.log")
 
-	if fastlaneErr := cmd.Run(); fastlaneErr != nil {
+	f.reportActionSummary(results, deployDir)
+
+	if shouldCollectBuildLogs(opts.BuildLogCollection, fastlaneErr != nil) {
+		f.collectBuildLogs(buildlogPth, deployDir)
+	}
+
+	summary := runSummary{
+		succeeded:     fastlaneErr == nil,
+		workDir:       opts.WorkDir,
+		workDirReason: opts.WorkDirReason,
+		authSource:    opts.AuthSource,
+		gemVersions:   opts.GemVersions,
+		useBundler:    opts.UseBundler,
+		rubyVersion:   opts.RubyVersion,
+		lanes:         results,
+		secrets:       secrets,
+	}
+
+	if fastlaneErr != nil {
+		f.reportFailureCategory(firstFailureClassifier(results))
+
 		f.logger.Println()
 		f.logger.Warnf(`Running Fastlane failed. If you want to send an issue report to Fastlane (https://github.com/fastlane/fastlane/issues/new),
 you can find the output of fastlane env in the following log file: %s`, deployPth)
 
-		if fastlaneDebugInfo, err := f.fastlaneDebugInfo(opts.WorkDir, opts.UseBundler, opts.GemVersions.bundler); err != nil {
+		fastlaneEnv := ""
+		if deployDir == "" {
+			f.logger.Warnf("No BITRISE_DEPLOY_DIR found, skipping writing the fastlane env log file")
+		} else if fastlaneDebugInfo, err := f.fastlaneDebugInfo(opts.WorkDir, opts.UseBundler, opts.GemVersions.bundler, bundlerEnvs(opts.GemfilePath, opts.BundlePath)); err != nil {
 			f.logger.Warnf("%s", err)
 		} else if fastlaneDebugInfo != "" {
-			if err := fileutil.WriteStringToFile(deployPth, fastlaneDebugInfo); err != nil {
+			fastlaneEnv = redactSecrets(fastlaneDebugInfo, diagnosticsSecrets(summary))
+			if err := fileutil.WriteStringToFile(deployPth, fastlaneEnv); err != nil {
 				f.logger.Warnf("Failed to write fastlane env log file, error: %s", err)
 			}
 		}
 
-		if err := filepath.Walk(buildlogPth, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				if relLogPath, err := filepath.Rel(buildlogPth, path); err != nil {
-					return err
-				} else if err := os.Rename(path, filepath.Join(deployDir, strings.Replace(relLogPath, "/", "_", -1))); err != nil {
-					return err
-				}
-			}
-			return nil
-		}); err != nil {
-			f.logger.Warnf("Failed to walk directory, error: %s", err)
+		f.writeRunSummary(opts.RunSummaryPath, deployDir, summary)
+		f.collectDiagnostics(deployDir, opts.WorkDir, opts.GemfilePath, summary, fastlaneEnv, tail)
+
+		category := errorCategoryLane
+		var timeoutErr *timeoutError
+		var promptErr *interactivePromptError
+		if errors.As(fastlaneErr, &timeoutErr) {
+			category = errorCategoryTimeout
+		} else if errors.As(fastlaneErr, &promptErr) {
+			category = errorCategoryInteractivePrompt
 		}
+		return newStepError(category, fmt.Errorf("running Fastlane failed: %w", fastlaneErr))
+	}
 
-		return fmt.Errorf("running Fastlane failed: %w", fastlaneErr)
+	f.exportLaneContext(laneContext, opts.LaneContextKeys)
+
+	if snapshot != nil {
+		summary.artifacts = f.exportArtifacts(*snapshot, opts.ArtifactExport, deployDir)
 	}
 
+	f.writeRunSummary(opts.RunSummaryPath, deployDir, summary)
+
 	f.cacheDeps(opts)
 
 	return nil
 }
 
-func (f FastlaneRunner) fastlaneDebugInfo(workDir string, useBundler bool, bundlerVersion gems.Version) (string, error) {
+func (f FastlaneRunner) runLane(opts RunOpts, laneOptions []string, envs []string, secrets []string, redactions *redactionCounter, handlers ...lineHandler) error {
+	secrets = append(append([]string{}, secrets...), secretLaneOptionValues(laneOptions)...)
+	f.printLaneOptions(laneOptions, secrets)
+
+	policy := opts.RetryPolicy
+	for attempt := 1; ; attempt++ {
+		matcher := newTransientErrorMatcher(policy.patterns)
+		err := f.runLaneAttempt(opts, laneOptions, envs, secrets, redactions, newLineWriter(append(handlers, matcher.handleLine)...))
+		if err == nil {
+			return nil
+		}
+
+		var timeoutErr *timeoutError
+		var promptErr *interactivePromptError
+		if attempt >= policy.maxAttempts || errors.As(err, &timeoutErr) || errors.As(err, &promptErr) {
+			return err
+		}
+		if matcher.match == "" {
+			f.logger.Debugf("Lane failure does not match any transient error pattern, not retrying")
+			return err
+		}
+
+		waitTime := policy.backoff(attempt)
+		f.logger.Println()
+		f.logger.Warnf("Lane failed with a transient error (attempt %d/%d): %s", attempt, policy.maxAttempts, matcher.match)
+		f.logger.Warnf("Retrying in %s...", waitTime)
+		time.Sleep(waitTime)
+	}
+}
+
+func (f FastlaneRunner) runLaneAttempt(opts RunOpts, laneOptions []string, envs []string, secrets []string, redactions *redactionCounter, outputWriter *lineWriter) error {
+	rbyFactory := f.rbyFactory
+	activity := newActivityWriter()
+	var prompts *promptWatcher
+	outputs := []io.Writer{outputWriter, activity}
+	if opts.FailOnInteractivePrompt {
+		prompts = newPromptWatcher()
+		outputs = append(outputs, prompts)
+	}
+	envRepository := env.NewRepository()
+	if opts.EnvAllowlist != nil {
+		envRepository = newFilteredEnvRepository(envRepository, opts.EnvAllowlist)
+	}
+	if opts.Timeouts.enabled() || opts.FailOnInteractivePrompt {
+		// fastlane is started in its own process group, so that it can be terminated together with its child processes
+		factory, err := ruby.NewCommandFactory(newProcessGroupCommandFactory(envRepository), f.cmdLocator)
+		if err != nil {
+			return err
+		}
+		rbyFactory = factory
+	} else if opts.EnvAllowlist != nil {
+		factory, err := ruby.NewCommandFactory(command.NewFactory(envRepository), f.cmdLocator)
+		if err != nil {
+			return err
+		}
+		rbyFactory = factory
+	}
+
+	// The output is redacted before it reaches the log and the line handlers
+	stdout := newRedactingWriter(io.MultiWriter(append([]io.Writer{f.stdout}, outputs...)...), secrets, redactions)
+	stderr := newRedactingWriter(io.MultiWriter(append([]io.Writer{f.stderr}, outputs...)...), secrets, redactions)
+	cmd := fastlaneLaneCommand(rbyFactory, opts, laneOptions, &command.Opts{
+		Stdout: stdout,
+		Stderr: stderr,
+		Dir:    opts.WorkDir,
+		// the command factory adds the process's environment
+		Env: envs,
+	})
+
+	f.logger.Donef("$ %s", redactSecrets(cmd.PrintableCommandArgs(), secrets))
+
+	var err error
+	if pgCmd, ok := cmd.(*processGroupCommand); ok {
+		err = f.runWithTimeout(pgCmd, opts.Timeouts, activity, prompts)
+	} else {
+		err = cmd.Run()
+	}
+	for _, w := range []*redactingWriter{stdout, stderr} {
+		if flushErr := w.Flush(); flushErr != nil {
+			f.logger.Warnf("Failed to write fastlane output: %s", flushErr)
+		}
+	}
+	outputWriter.Flush()
+
+	return err
+}
+
+func fastlaneLaneCommand(rbyFactory ruby.CommandFactory, opts RunOpts, laneOptions []string, cmdOpts *command.Opts) command.Command {
+	name := "fastlane"
+	args := laneOptions
+	if opts.UseBundler {
+		return rbyFactory.CreateBundleExec(name, args, opts.GemVersions.bundler.Version, cmdOpts)
+	}
+	return rbyFactory.Create(name, args, cmdOpts)
+}
+
+func firstFailureClassifier(results []laneResult) *failureClassifier {
+	for _, result := range results {
+		if result.err != nil {
+			return result.classifier
+		}
+	}
+	return nil
+}
+
+func (f FastlaneRunner) printLaneResults(results []laneResult) {
+	f.logger.Println()
+	f.logger.Infof("Lane results")
+
+	for _, result := range results {
+		switch {
+		case result.skipped:
+			f.logger.Warnf("- %s: skipped", result.lane)
+		case result.err != nil:
+			f.logger.Errorf("- %s: failed (%s)", result.lane, result.duration.Round(time.Second))
+		default:
+			f.logger.Donef("- %s: succeeded (%s)", result.lane, result.duration.Round(time.Second))
+		}
+	}
+}
+
+func (f FastlaneRunner) fastlaneDebugInfo(workDir string, useBundler bool, bundlerVersion gems.Version, bundlerEnvs []string) (string, error) {
 	factory, err := ruby.NewCommandFactory(f.cmdFactory, f.cmdLocator)
 	if err != nil {
 		return "", err
@@ -138,6 +395,7 @@ func (f FastlaneRunner) fastlaneDebugInfo(workDir string, useBundler bool, bundl
 	}
 	var cmd command.Command
 	if useBundler {
+		opts.Env = bundlerEnvs
 		cmd = factory.CreateBundleExec(name, args, bundlerVersion.Version, opts)
 	} else {
 		cmd = factory.Create(name, args, opts)
@@ -151,6 +409,9 @@ func (f FastlaneRunner) fastlaneDebugInfo(workDir string, useBundler bool, bundl
 		}
 		return "", fmt.Errorf("Fastlane command failed (%s): %w", cmd.PrintableCommandArgs(), err)
 	}
+	if err := outWriter.Flush(); err != nil {
+		return "", err
+	}
 
 	return outBuffer.String(), nil
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const runSummaryOutputKey = "FASTLANE_RUN_SUMMARY_PATH"
+
+// runSummary is the data of the Markdown summary written at the end of the Step run.
+type runSummary struct {
+	succeeded     bool
+	workDir       string
+	workDirReason string
+	authSource    string
+	gemVersions   gemVersions
+	useBundler    bool
+	rubyVersion   string
+	lanes         []laneResult
+	secrets       []string
+	// artifacts are the paths of the artifacts exported to the deploy dir
+	artifacts []string
+}
+
+// redactedLane returns the lane with its options, masking the values of secret options and the secrets.
+func redactedLane(laneOptions []string, secrets []string) string {
+	var options []string
+	for _, option := range laneOptions {
+		if key, ok := laneOptionKey(option); ok && isSecretKey(key) && len(option) > len(key)+1 {
+			option = key + ":" + redactedValue
+		}
+		options = append(options, option)
+	}
+	return redactSecrets(strings.Join(options, " "), secrets)
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+func (s runSummary) markdown() string {
+	var b strings.Builder
+
+	b.WriteString("## fastlane\n\n")
+	if s.succeeded {
+		b.WriteString("**Result:** ✅ succeeded\n\n")
+	} else {
+		b.WriteString("**Result:** ❌ failed\n\n")
+	}
+
+	b.WriteString(fmt.Sprintf("**Working directory:** `%s` (%s)\n\n", s.workDir, s.workDirReason))
+
+	authSource := s.authSource
+	if authSource == "" {
+		authSource = "none"
+	}
+	b.WriteString(fmt.Sprintf("**Apple Service authentication:** %s\n\n", authSource))
+
+	fastlaneVersion := "system installed"
+	if s.gemVersions.fastlane.Found {
+		fastlaneVersion = s.gemVersions.fastlane.Version + " (Gemfile.lock)"
+	}
+	bundlerVersion := "not used"
+	if s.useBundler {
+		bundlerVersion = "default"
+		if s.gemVersions.bundler.Found {
+			bundlerVersion = s.gemVersions.bundler.Version + " (Gemfile.lock)"
+		}
+	}
+	rubyVersion := s.rubyVersion
+	if rubyVersion == "" {
+		rubyVersion = "unknown"
+	}
+	b.WriteString("| Tool | Version |\n|---|---|\n")
+	b.WriteString(fmt.Sprintf("| fastlane | %s |\n", fastlaneVersion))
+	b.WriteString(fmt.Sprintf("| bundler | %s |\n", bundlerVersion))
+	b.WriteString(fmt.Sprintf("| Ruby | %s |\n\n", rubyVersion))
+
+	b.WriteString("### Lanes\n\n")
+	b.WriteString("| Lane | Result | Duration |\n|---|---|---|\n")
+	for _, lane := range s.lanes {
+		result := "✅ succeeded"
+		duration := lane.duration.Round(time.Second).String()
+		switch {
+		case lane.skipped:
+			result = "⏭ skipped"
+			duration = "-"
+		case lane.err != nil:
+			result = "❌ failed"
+			if lane.classifier != nil && lane.classifier.category != nil {
+				result += " (" + lane.classifier.category.title + ")"
+			}
+		}
+		b.WriteString(fmt.Sprintf("| `%s` | %s | %s |\n", markdownCell(redactedLane(lane.laneOptions, s.secrets)), result, duration))
+	}
+	b.WriteString("\n")
+
+	if len(actionSummaries(s.lanes)) > 0 {
+		b.WriteString("### Action timings\n")
+		for _, lane := range s.lanes {
+			if lane.actionSummary == nil || len(lane.actionSummary.actions) == 0 {
+				continue
+			}
+			b.WriteString(fmt.Sprintf("\n`%s`\n\n", markdownCell(redactedLane(lane.laneOptions, s.secrets))))
+			b.WriteString("| Step | Action | Time (s) |\n|---|---|---|\n")
+			for _, action := range lane.actionSummary.actions {
+				name := markdownCell(action.Action)
+				if action.Failed {
+					name += " ❌"
+				}
+				b.WriteString(fmt.Sprintf("| %d | %s | %s |\n", action.Step, name, strconv.FormatFloat(action.DurationSeconds, 'f', -1, 64)))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if len(s.artifacts) > 0 {
+		b.WriteString("### Artifacts\n\n")
+		for _, pth := range s.artifacts {
+			b.WriteString(fmt.Sprintf("- `%s`\n", pth))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// writeRunSummary writes the Markdown summary to the path and exports the path as a Step output.
+// The summary is not written without a deploy dir, as its default path is in the deploy dir.
+func (f FastlaneRunner) writeRunSummary(pth, deployDir string, summary runSummary) {
+	if pth == "" {
+		return
+	}
+	if deployDir == "" {
+		f.logger.Warnf("No BITRISE_DEPLOY_DIR found, skipping writing the run summary")
+		return
+	}
+
+	absPth, err := f.pathModifier.AbsPath(pth)
+	if err != nil {
+		f.logger.Warnf("Failed to expand run summary path (%s): %s", pth, err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(absPth), 0755); err != nil {
+		f.logger.Warnf("Failed to create run summary directory: %s", err)
+		return
+	}
+	if err := os.WriteFile(absPth, []byte(summary.markdown()), 0644); err != nil {
+		f.logger.Warnf("Failed to write run summary: %s", err)
+		return
+	}
+
+	if err := f.outputExporter.ExportOutput(runSummaryOutputKey, absPth); err != nil {
+		f.logger.Warnf("Failed to export %s: %s", runSummaryOutputKey, err)
+		return
+	}
+	f.logger.Donef("%s: %s", runSummaryOutputKey, absPth)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRunSummary() runSummary {
+	classifier := newFailureClassifier()
+	classifier.handleLine(`No signing certificate "iOS Distribution" found`)
+
+	actionSummary := newActionSummaryParser()
+	actionSummary.actions = []actionTiming{
+		{Step: 1, Action: "match", DurationSeconds: 12},
+		{Step: 2, Action: "gym", DurationSeconds: 245.5, Failed: true},
+	}
+
+	return runSummary{
+		workDir:       "/bitrise/src",
+		workDirReason: "Fastfile found in the working directory (/bitrise/src/fastlane/Fastfile)",
+		authSource:    "Connected App Store Connect API key",
+		gemVersions: gemVersions{
+			fastlane: gems.Version{Version: "2.219.0", Found: true},
+			bundler:  gems.Version{Version: "2.4.10", Found: true},
+		},
+		useBundler:  true,
+		rubyVersion: "3.2.2",
+		lanes: []laneResult{
+			{lane: "ios test", laneOptions: []string{"ios", "test"}, duration: 61 * time.Second},
+			{lane: "ios beta", laneOptions: []string{"ios", "beta", "api_token:abc123", "notes:built by s3cr3t"}, duration: 5 * time.Minute, err: errors.New("exit status 1"), classifier: classifier, actionSummary: actionSummary},
+			{lane: "ios release", laneOptions: []string{"ios", "release"}, skipped: true},
+		},
+		secrets: []string{"s3cr3t"},
+	}
+}
+
+func Test_GivenFailedRun_WhenSummaryCreated_ThenReceiveMarkdown(t *testing.T) {
+	expected := "## fastlane\n\n" +
+		"**Result:** ❌ failed\n\n" +
+		"**Working directory:** `/bitrise/src` (Fastfile found in the working directory (/bitrise/src/fastlane/Fastfile))\n\n" +
+		"**Apple Service authentication:** Connected App Store Connect API key\n\n" +
+		"| Tool | Version |\n|---|---|\n" +
+		"| fastlane | 2.219.0 (Gemfile.lock) |\n" +
+		"| bundler | 2.4.10 (Gemfile.lock) |\n" +
+		"| Ruby | 3.2.2 |\n\n" +
+		"### Lanes\n\n" +
+		"| Lane | Result | Duration |\n|---|---|---|\n" +
+		"| `ios test` | ✅ succeeded | 1m1s |\n" +
+		"| `ios beta api_token:[REDACTED] notes:built by [REDACTED]` | ❌ failed (Code signing error) | 5m0s |\n" +
+		"| `ios release` | ⏭ skipped | - |\n\n" +
+		"### Action timings\n\n" +
+		"`ios beta api_token:[REDACTED] notes:built by [REDACTED]`\n\n" +
+		"| Step | Action | Time (s) |\n|---|---|---|\n" +
+		"| 1 | match | 12 |\n" +
+		"| 2 | gym ❌ | 245.5 |\n\n"
+
+	assert.Equal(t, expected, testRunSummary().markdown())
+}
+
+func Test_GivenSucceededRunWithArtifacts_WhenSummaryWritten_ThenPathIsExported(t *testing.T) {
+	summary := testRunSummary()
+	summary.succeeded = true
+	summary.lanes = summary.lanes[:1]
+	summary.gemVersions = gemVersions{}
+	summary.useBundler = false
+	summary.authSource = ""
+	summary.artifacts = []string{"/bitrise/deploy/App.ipa", "/bitrise/deploy/App.app.dSYM.zip"}
+
+	markdown := summary.markdown()
+	assert.Contains(t, markdown, "**Result:** ✅ succeeded\n\n")
+	assert.Contains(t, markdown, "**Apple Service authentication:** none\n\n")
+	assert.Contains(t, markdown, "| fastlane | system installed |\n| bundler | not used |\n")
+	assert.Contains(t, markdown, "### Artifacts\n\n- `/bitrise/deploy/App.ipa`\n- `/bitrise/deploy/App.app.dSYM.zip`\n")
+	assert.NotContains(t, markdown, "### Action timings")
+
+	exporter := fakeOutputExporter{}
+	step := FastlaneRunner{logger: log.NewLogger(), pathModifier: pathutil.NewPathModifier(), outputExporter: exporter}
+	deployDir := t.TempDir()
+	pth := filepath.Join(deployDir, "summary", "fastlane_run_summary.md")
+	step.writeRunSummary(pth, deployDir, summary)
+
+	content, err := os.ReadFile(pth)
+	assert.NoError(t, err)
+	assert.Equal(t, markdown, string(content))
+	assert.Equal(t, pth, exporter[runSummaryOutputKey])
+}
+
+func Test_GivenNoDeployDir_WhenWriteRunSummary_ThenSummaryIsNotWritten(t *testing.T) {
+	exporter := fakeOutputExporter{}
+	step := FastlaneRunner{logger: log.NewLogger(), pathModifier: pathutil.NewPathModifier(), outputExporter: exporter}
+	pth := filepath.Join(t.TempDir(), "fastlane_run_summary.md")
+
+	step.writeRunSummary(pth, "", runSummary{})
+
+	assert.NoFileExists(t, pth)
+	assert.Empty(t, exporter)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/v2/stepconf"
+	"github.com/bitrise-io/go-xcode/appleauth"
+)
+
+const redactedValue = "[REDACTED]"
+
+// minSecretLength is the length of the shortest value treated as a secret, masking shorter values would make logs unreadable.
+const minSecretLength = 3
+
+// secretKeyRegexp matches option and environment variable names which usually hold secrets.
+var secretKeyRegexp = regexp.MustCompile(`(?i)(password|passphrase|secret|token|session|private_key|api_key|apikey|credential)`)
+
+// nonSecretAuthEnvKeys are the environment variables set by FastlaneAuthParams, which do not hold secrets.
+// Only the passwords, the app-specific password, the API key and the session are secrets, the Apple ID username is not.
+var nonSecretAuthEnvKeys = map[string]bool{
+	"FASTLANE_USER":                     true,
+	"DELIVER_USERNAME":                  true,
+	"PILOT_USERNAME":                    true,
+	"SPACESHIP_SKIP_2FA_UPGRADE":        true,
+	"PRECHECK_INCLUDE_IN_APP_PURCHASES": true,
+	"APP_STORE_CONNECT_API_KEY_PATH":    true,
+	"DELIVER_API_KEY_PATH":              true,
+	"PILOT_API_KEY_PATH":                true,
+}
+
+func isSecretKey(key string) bool {
+	return secretKeyRegexp.MatchString(key)
+}
+
+// secretInputValues returns the values of the stepconf.Secret fields of the inputs struct.
+func secretInputValues(inputs interface{}) []string {
+	var secrets []string
+	v := reflect.Indirect(reflect.ValueOf(inputs))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	secretType := reflect.TypeOf(stepconf.Secret(""))
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Type() == secretType:
+			secrets = append(secrets, field.String())
+		case field.Kind() == reflect.Struct:
+			secrets = append(secrets, secretInputValues(field.Interface())...)
+		}
+	}
+	return secrets
+}
+
+// envSecretValues returns the values of the environment variables.
+func envSecretValues(keys []string) []string {
+	var secrets []string
+	for _, key := range keys {
+		if value := os.Getenv(strings.TrimSpace(key)); value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// authSecretValues returns the secret values of the Apple authentication, including the envs created by FastlaneAuthParams.
+func authSecretValues(authEnvs map[string]string, credentials appleauth.Credentials) []string {
+	var secrets []string
+	for key, value := range authEnvs {
+		if !nonSecretAuthEnvKeys[key] {
+			secrets = append(secrets, value)
+		}
+	}
+	if credentials.APIKey != nil {
+		secrets = append(secrets, credentials.APIKey.PrivateKey)
+	}
+	return secrets
+}
+
+// normalizeSecrets removes the duplicated and too short values and orders the secrets by length,
+// so that a secret containing another one is masked as a whole.
+func normalizeSecrets(secrets []string) []string {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength || seen[secret] {
+			continue
+		}
+		seen[secret] = true
+		normalized = append(normalized, secret)
+	}
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return len(normalized[i]) > len(normalized[j])
+	})
+	return normalized
+}
+
+func redactSecrets(s string, secrets []string) string {
+	for _, secret := range normalizeSecrets(secrets) {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/bitrise-io/go-utils/v2/exitcode"
+)
+
+const (
+	errorRecordFileName  = "fastlane_error.json"
+	errorRecordOutputKey = "FASTLANE_ERROR_RECORD_PATH"
+)
+
+// errorCategory is the Step phase an error occurred in.
+type errorCategory string
+
+const (
+	errorCategoryConfig            errorCategory = "config"
+	errorCategoryAuth              errorCategory = "auth"
+	errorCategoryRubyToolchain     errorCategory = "ruby_toolchain"
+	errorCategoryDependencyInstall errorCategory = "dependency_install"
+	errorCategoryFastlaneVersion   errorCategory = "fastlane_version"
+	errorCategoryLane              errorCategory = "lane"
+	errorCategoryTimeout           errorCategory = "timeout"
+	// errorCategoryCache is only reported to the analytics, a cache failure does not fail the Step.
+	errorCategoryCache             errorCategory = "cache"
+	errorCategoryInteractivePrompt errorCategory = "interactive_prompt"
+)
+
+// errorCategoryExitCodes are the exit codes of the error categories.
+// Lane failures keep the generic failure exit code, so that existing scripts checking for it keep working.
+var errorCategoryExitCodes = map[errorCategory]ExitCode{
+	errorCategoryLane:              Failure,
+	errorCategoryConfig:            10,
+	errorCategoryAuth:              11,
+	errorCategoryRubyToolchain:     12,
+	errorCategoryDependencyInstall: 13,
+	errorCategoryFastlaneVersion:   14,
+	errorCategoryTimeout:           15,
+	errorCategoryCache:             16,
+	errorCategoryInteractivePrompt: 17,
+}
+
+// stepError is an error, which category is known.
+type stepError struct {
+	category errorCategory
+	err      error
+}
+
+func newStepError(category errorCategory, err error) error {
+	return &stepError{category: category, err: err}
+}
+
+func (e *stepError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+// categoryOf returns the category of the (wrapped) stepError, or the default category of the phase the error was returned from.
+func categoryOf(err error, defaultCategory errorCategory) errorCategory {
+	var stepErr *stepError
+	if errors.As(err, &stepErr) {
+		return stepErr.category
+	}
+	return defaultCategory
+}
+
+// errorRecord is the machine-readable description of the error the Step failed with.
+type errorRecord struct {
+	Category string `json:"category"`
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message"`
+}
+
+func newErrorRecord(err error, defaultCategory errorCategory) errorRecord {
+	category := categoryOf(err, defaultCategory)
+	return errorRecord{
+		Category: string(category),
+		ExitCode: int(errorCategoryExitCodes[category]),
+		Message:  err.Error(),
+	}
+}
+
+// reportError writes the error record to the deploy dir, exports its path and returns the exit code of the error's category.
+func (f FastlaneRunner) reportError(err error, defaultCategory errorCategory) ExitCode {
+	record := newErrorRecord(err, defaultCategory)
+	f.tracker.logStepError(record.Category)
+
+	if deployDir := os.Getenv("BITRISE_DEPLOY_DIR"); deployDir != "" {
+		pth := filepath.Join(deployDir, errorRecordFileName)
+		if b, err := json.MarshalIndent(record, "", "  "); err != nil {
+			f.logger.Warnf("Failed to encode error record: %s", err)
+		} else if err := os.WriteFile(pth, b, 0644); err != nil {
+			f.logger.Warnf("Failed to write error record: %s", err)
+		} else if err := f.outputExporter.ExportOutput(errorRecordOutputKey, pth); err != nil {
+			f.logger.Warnf("Failed to export %s: %s", errorRecordOutputKey, err)
+		}
+	}
+
+	f.logger.Printf("Error category: %s (exit code %d)", record.Category, record.ExitCode)
+	return ExitCode(record.ExitCode)
+}
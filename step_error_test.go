@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenErrorCategories_WhenMappedToExitCodes_ThenExitCodesAreDistinct(t *testing.T) {
+	seen := map[int]errorCategory{}
+	for category, exitCode := range errorCategoryExitCodes {
+		other, ok := seen[int(exitCode)]
+		assert.False(t, ok, "%s and %s have the same exit code", category, other)
+		seen[int(exitCode)] = category
+	}
+}
+
+func Test_GivenWrappedStepError_WhenRecordCreated_ThenCategoryOfTheStepErrorIsUsed(t *testing.T) {
+	err := fmt.Errorf("running Fastlane failed: %w", newStepError(errorCategoryTimeout, &timeoutError{reason: "no output for 10m0s"}))
+
+	assert.Equal(t, errorRecord{
+		Category: "timeout",
+		ExitCode: 15,
+		Message:  "running Fastlane failed: fastlane timed out: no output for 10m0s",
+	}, newErrorRecord(err, errorCategoryLane))
+
+	var timeoutErr *timeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+}
+
+func Test_GivenErrorWithoutCategory_WhenRecordCreated_ThenDefaultCategoryIsUsed(t *testing.T) {
+	assert.Equal(t, errorRecord{
+		Category: "config",
+		ExitCode: 10,
+		Message:  "No lane specified",
+	}, newErrorRecord(errors.New("No lane specified"), errorCategoryConfig))
+}
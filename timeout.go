@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// terminationGracePeriod is the time fastlane has to exit after SIGTERM, before it gets killed.
+const terminationGracePeriod = 30 * time.Second
+
+type timeoutOpts struct {
+	timeout         time.Duration
+	noOutputTimeout time.Duration
+}
+
+func (o timeoutOpts) enabled() bool {
+	return o.timeout > 0 || o.noOutputTimeout > 0
+}
+
+// timeoutError is returned when fastlane was terminated by one of the timeouts.
+type timeoutError struct {
+	reason string
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("fastlane timed out: %s", e.reason)
+}
+
+// activityWriter records the time of the last output written into it.
+type activityWriter struct {
+	lastWrite atomic.Int64
+}
+
+func newActivityWriter() *activityWriter {
+	w := &activityWriter{}
+	w.lastWrite.Store(time.Now().UnixNano())
+	return w
+}
+
+// Write ...
+func (w *activityWriter) Write(p []byte) (int, error) {
+	w.lastWrite.Store(time.Now().UnixNano())
+	return len(p), nil
+}
+
+func (w *activityWriter) idleTime() time.Duration {
+	return time.Since(time.Unix(0, w.lastWrite.Load()))
+}
+
+// runWithTimeout runs the command and terminates its process group if it runs longer than the timeout,
+// it does not produce any output for the no output timeout, or it waits for an answer to an interactive prompt (if prompts are watched).
+// Interrupt and termination signals received by the Step are forwarded to the process group while the command runs.
+func (f FastlaneRunner) runWithTimeout(cmd *processGroupCommand, opts timeoutOpts, activity *activityWriter, prompts *promptWatcher) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeoutC <-chan time.Time
+	if opts.timeout > 0 {
+		timer := time.NewTimer(opts.timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	var noOutputCheckC <-chan time.Time
+	if opts.noOutputTimeout > 0 {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		noOutputCheckC = ticker.C
+	}
+
+	var promptCheckC <-chan time.Time
+	if prompts != nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		promptCheckC = ticker.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case sig := <-signals:
+			if err := cmd.signal(sig.(syscall.Signal)); err != nil {
+				f.logger.Warnf("Failed to forward %s to fastlane: %s", sig, err)
+			}
+		case <-timeoutC:
+			reason := fmt.Sprintf("the lane did not finish within %s", opts.timeout)
+			return f.terminate(cmd, done, reason, &timeoutError{reason: reason})
+		case <-noOutputCheckC:
+			if activity.idleTime() >= opts.noOutputTimeout {
+				reason := fmt.Sprintf("no output received for %s", opts.noOutputTimeout)
+				return f.terminate(cmd, done, reason, &timeoutError{reason: reason})
+			}
+		case <-promptCheckC:
+			if promptErr := prompts.waitingPrompt(activity.idleTime()); promptErr != nil {
+				return f.terminate(cmd, done, fmt.Sprintf("fastlane is waiting for input (%s)", promptErr.line), promptErr)
+			}
+		}
+	}
+}
+
+// terminate stops the command's process group and returns the error describing why it was stopped.
+func (f FastlaneRunner) terminate(cmd *processGroupCommand, done <-chan error, reason string, stopErr error) error {
+	f.logger.Println()
+	f.logger.Errorf("%s, terminating fastlane", reason)
+
+	if err := cmd.signal(syscall.SIGTERM); err != nil {
+		f.logger.Warnf("Failed to send SIGTERM to fastlane: %s", err)
+	}
+
+	select {
+	case <-done:
+		return stopErr
+	case <-time.After(terminationGracePeriod):
+	}
+
+	f.logger.Warnf("fastlane did not exit within %s, killing it", terminationGracePeriod)
+	if err := cmd.signal(syscall.SIGKILL); err != nil {
+		f.logger.Warnf("Failed to send SIGKILL to fastlane: %s", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(terminationGracePeriod):
+		f.logger.Warnf("fastlane output is still open after killing the process group, continuing")
+	}
+
+	return stopErr
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenHangingCommand_WhenNoOutputTimeoutElapses_ThenProcessGroupIsTerminated(t *testing.T) {
+	step := FastlaneRunner{logger: log.NewLogger()}
+	activity := newActivityWriter()
+	factory := newProcessGroupCommandFactory(env.NewRepository())
+	cmd := factory.Create("sh", []string{"-c", "echo started; sleep 60 & wait"}, &command.Opts{Stdout: activity, Stderr: activity})
+
+	startTime := time.Now()
+	err := step.runWithTimeout(cmd.(*processGroupCommand), timeoutOpts{noOutputTimeout: time.Second}, activity, nil)
+
+	var timeoutErr *timeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	assert.Less(t, time.Since(startTime), 10*time.Second)
+}
+
+func Test_GivenFinishingCommand_WhenRunWithTimeout_ThenCommandErrorIsReturned(t *testing.T) {
+	step := FastlaneRunner{logger: log.NewLogger()}
+	activity := newActivityWriter()
+	factory := newProcessGroupCommandFactory(env.NewRepository())
+	cmd := factory.Create("sh", []string{"-c", "exit 3"}, &command.Opts{Stdout: activity, Stderr: activity})
+
+	err := step.runWithTimeout(cmd.(*processGroupCommand), timeoutOpts{timeout: time.Minute}, activity, nil)
+
+	var timeoutErr *timeoutError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &timeoutErr))
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/bitrise-io/go-utils/v2/analytics"
 	"github.com/bitrise-io/go-utils/v2/env"
 	"github.com/bitrise-io/go-utils/v2/log"
@@ -30,6 +32,51 @@ func (t *stepTracker) logEffectiveRubyVersion(versionString string) {
 	t.tracker.Enqueue("step_ruby_version_selected", properties)
 }
 
+func (t *stepTracker) logFailureCategory(category string) {
+	properties := analytics.Properties{
+		"failure_category": category,
+	}
+	t.tracker.Enqueue("step_fastlane_failure_classified", properties)
+}
+
+func (t *stepTracker) logActionTiming(lane string, action actionTiming) {
+	properties := analytics.Properties{
+		"lane":             lane,
+		"action":           action.Action,
+		"step":             action.Step,
+		"duration_seconds": action.DurationSeconds,
+		"failed":           action.Failed,
+	}
+	t.tracker.Enqueue("step_fastlane_action_finished", properties)
+}
+
+func (t *stepTracker) logDependencyPhases(phases []dependencyPhase, fastPath, succeeded bool) {
+	var total time.Duration
+	for _, phase := range phases {
+		total += phase.duration
+		properties := analytics.Properties{
+			"phase":            phase.name,
+			"duration_seconds": phase.duration.Seconds(),
+			"fast_path":        fastPath,
+		}
+		t.tracker.Enqueue("step_dependency_phase_finished", properties)
+	}
+
+	properties := analytics.Properties{
+		"duration_seconds": total.Seconds(),
+		"fast_path":        fastPath,
+		"succeeded":        succeeded,
+	}
+	t.tracker.Enqueue("step_dependencies_installed", properties)
+}
+
+func (t *stepTracker) logStepError(category string) {
+	properties := analytics.Properties{
+		"error_category": category,
+	}
+	t.tracker.Enqueue("step_fastlane_error", properties)
+}
+
 func (t *stepTracker) wait() {
 	t.tracker.Wait()
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fastlaneDirSearchMaxDepth limits how deep the repository is searched for fastlane directories.
+const fastlaneDirSearchMaxDepth = 6
+
+// findWorkDirCandidates returns the directories under the root, which contain a fastlane directory with a Fastfile.
+// These are the directories fastlane can be run from.
+// The unreadable entries are skipped with a warning, only an unreadable root is an error.
+func (f FastlaneRunner) findWorkDirCandidates(root string) ([]string, error) {
+	candidates := map[string]bool{}
+	err := filepath.Walk(root, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			if pth == root {
+				return err
+			}
+			f.logger.Warnf("Skipping %s while searching for the fastlane directory: %s", pth, err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if pth == root {
+				return nil
+			}
+			if artifactSearchSkipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			if rel, err := filepath.Rel(root, pth); err == nil && len(strings.Split(rel, string(filepath.Separator))) > fastlaneDirSearchMaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Name() != "Fastfile" && info.Name() != "Fastfile.swift" {
+			return nil
+		}
+		fastlaneDir := filepath.Dir(pth)
+		if name := filepath.Base(fastlaneDir); name == "fastlane" || name == ".fastlane" {
+			candidates[filepath.Dir(fastlaneDir)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for dir := range candidates {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// detectWorkDir checks whether fastlane can find a Fastfile in the work dir,
+// if not, it searches the repository for the fastlane directory.
+// It returns the work dir to use and the reason it was chosen.
+func (f FastlaneRunner) detectWorkDir(workDir, repositoryDir string) (string, string, error) {
+	if fastfilePth, _ := findFastfile(workDir); fastfilePth != "" {
+		return workDir, fmt.Sprintf("Fastfile found in the working directory (%s)", fastfilePth), nil
+	}
+
+	searchDir := workDir
+	if repositoryDir != "" && isPathInDir(workDir, repositoryDir) {
+		searchDir = repositoryDir
+	}
+	f.logger.Warnf("No Fastfile found in the working directory (%s), searching %s for the fastlane directory", workDir, searchDir)
+
+	candidates, err := f.findWorkDirCandidates(searchDir)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to search for the fastlane directory, error: %s", err)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return workDir, "no fastlane directory found in the repository, using the working directory input", nil
+	case 1:
+		f.logger.Warnf("Using the only directory containing a fastlane directory as the working directory: %s", candidates[0])
+		f.logger.Warnf("Set the working directory input to the parent directory of the Fastfile's directory to avoid this warning")
+		return candidates[0], fmt.Sprintf("no Fastfile in the working directory input (%s), the only fastlane directory found is in %s", workDir, candidates[0]), nil
+	default:
+		return "", "", fmt.Errorf("No Fastfile found in the working directory (%s) and multiple fastlane directories found, set the working directory input to one of:\n- %s", workDir, strings.Join(candidates, "\n- "))
+	}
+}
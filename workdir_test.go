@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenFastfileInWorkDir_WhenDetectingWorkDir_ThenWorkDirIsKept(t *testing.T) {
+	repositoryDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(repositoryDir, "fastlane", "Fastfile"), "lane :beta do\nend\n")
+	writeTestFileContent(t, filepath.Join(repositoryDir, "other", "fastlane", "Fastfile"), "lane :beta do\nend\n")
+
+	runner := FastlaneRunner{logger: log.NewLogger()}
+	workDir, reason, err := runner.detectWorkDir(repositoryDir, repositoryDir)
+	assert.NoError(t, err)
+	assert.Equal(t, repositoryDir, workDir)
+	assert.Contains(t, reason, "Fastfile found in the working directory")
+}
+
+func Test_GivenSingleFastlaneDir_WhenDetectingWorkDir_ThenItsParentIsUsed(t *testing.T) {
+	repositoryDir := t.TempDir()
+	appDir := filepath.Join(repositoryDir, "apps", "ios")
+	writeTestFileContent(t, filepath.Join(appDir, "fastlane", "Fastfile"), "lane :beta do\nend\n")
+	writeTestFileContent(t, filepath.Join(repositoryDir, "node_modules", "pkg", "fastlane", "Fastfile"), "lane :beta do\nend\n")
+
+	runner := FastlaneRunner{logger: log.NewLogger()}
+
+	workDir, reason, err := runner.detectWorkDir(repositoryDir, repositoryDir)
+	assert.NoError(t, err)
+	assert.Equal(t, appDir, workDir)
+	assert.Contains(t, reason, "the only fastlane directory found")
+
+	// work dir pointing to an other directory of the repository
+	workDir, _, err = runner.detectWorkDir(filepath.Join(repositoryDir, "apps"), repositoryDir)
+	assert.NoError(t, err)
+	assert.Equal(t, appDir, workDir)
+}
+
+func Test_GivenMultipleFastlaneDirs_WhenDetectingWorkDir_ThenReceiveErrorListingThem(t *testing.T) {
+	repositoryDir := t.TempDir()
+	writeTestFileContent(t, filepath.Join(repositoryDir, "ios", "fastlane", "Fastfile"), "lane :beta do\nend\n")
+	writeTestFileContent(t, filepath.Join(repositoryDir, "android", "fastlane", "Fastfile"), "lane :beta do\nend\n")
+
+	runner := FastlaneRunner{logger: log.NewLogger()}
+	_, _, err := runner.detectWorkDir(repositoryDir, repositoryDir)
+	assert.EqualError(t, err, "No Fastfile found in the working directory ("+repositoryDir+") and multiple fastlane directories found, set the working directory input to one of:\n- "+
+		filepath.Join(repositoryDir, "android")+"\n- "+filepath.Join(repositoryDir, "ios"))
+}
+
+func Test_GivenUnreadableDir_WhenDetectingWorkDir_ThenItIsSkipped(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced for root")
+	}
+
+	repositoryDir := t.TempDir()
+	appDir := filepath.Join(repositoryDir, "ios")
+	writeTestFileContent(t, filepath.Join(appDir, "fastlane", "Fastfile"), "lane :beta do\nend\n")
+	unreadableDir := filepath.Join(repositoryDir, "private")
+	assert.NoError(t, os.Mkdir(unreadableDir, 0000))
+	t.Cleanup(func() { _ = os.Chmod(unreadableDir, 0755) })
+
+	runner := FastlaneRunner{logger: log.NewLogger()}
+	workDir, _, err := runner.detectWorkDir(repositoryDir, repositoryDir)
+	assert.NoError(t, err)
+	assert.Equal(t, appDir, workDir)
+}
+
+func Test_GivenNoFastlaneDir_WhenDetectingWorkDir_ThenWorkDirIsKept(t *testing.T) {
+	repositoryDir := t.TempDir()
+
+	runner := FastlaneRunner{logger: log.NewLogger()}
+	workDir, _, err := runner.detectWorkDir(repositoryDir, repositoryDir)
+	assert.NoError(t, err)
+	assert.Equal(t, repositoryDir, workDir)
+}